@@ -0,0 +1,105 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// altNameSize is ALTIFNAMSIZ from the kernel, which includes the
+	// trailing NUL.
+	altNameSize = 128
+)
+
+func (i Interface) altNameRequest(proto int, name string) error {
+	if name == "" {
+		return fmt.Errorf("tap: alternative name is empty")
+	}
+	if len(name) >= altNameSize {
+		return fmt.Errorf("tap: alternative name is too long")
+	}
+
+	flags := unix.NLM_F_ACK
+	if proto == unix.RTM_NEWLINKPROP {
+		// These overlap with NLM_F_BULK for deletes, so only set them
+		// when adding.
+		flags |= unix.NLM_F_EXCL | unix.NLM_F_CREATE
+	}
+
+	req := nl.NewNetlinkRequest(proto, flags)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(i.ps.index())
+	req.AddData(msg)
+
+	props := nl.NewRtAttr(unix.IFLA_PROP_LIST|unix.NLA_F_NESTED, nil)
+	props.AddRtAttr(unix.IFLA_ALT_IFNAME, nl.ZeroTerminated(name))
+	req.AddData(props)
+
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
+
+// AddAltName will add an alternative name to the Interface. Alternative
+// names may be up to 127 bytes long, and can be used anywhere the kernel
+// accepts an interface name (such as `ip link show`).
+func (i Interface) AddAltName(name string) error {
+	return i.altNameRequest(unix.RTM_NEWLINKPROP, name)
+}
+
+// RemoveAltName will remove an alternative name from the Interface.
+func (i Interface) RemoveAltName(name string) error {
+	return i.altNameRequest(unix.RTM_DELLINKPROP, name)
+}
+
+// AltNames will return the alternative names of the Interface.
+func (i Interface) AltNames() ([]string, error) {
+	attrs, err := i.linkAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, attr := range attrs {
+		if attr.Attr.Type&^unix.NLA_F_NESTED != unix.IFLA_PROP_LIST {
+			continue
+		}
+		props, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return nil, err
+		}
+		for _, prop := range props {
+			if prop.Attr.Type != unix.IFLA_ALT_IFNAME {
+				continue
+			}
+			ret = append(ret, string(bytes.TrimRight(prop.Value, "\x00")))
+		}
+	}
+	return ret, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,164 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"net"
+	"time"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// AnnounceOptions controls how an Interface announces its own addresses to
+// peers on the other end of the TAP.
+type AnnounceOptions struct {
+	// Automatic will announce addresses after they are added with AddAddr,
+	// and every address on the Interface after the hardware address is
+	// changed with SetHardwareAddr, or the Interface is brought up with
+	// SetUp. Nothing is announced while the Interface is down, and IPv6
+	// addresses are announced once Duplicate Address Detection is done.
+	//
+	// Automatic announcements are best effort, and failures to send them
+	// are not returned from AddAddr, SetHardwareAddr or SetUp.
+	Automatic bool
+
+	// Count is the number of announcements to send for each address. If
+	// unset, a single announcement is sent.
+	Count int
+
+	// Interval is the time between repeated announcements. If unset, this
+	// defaults to one second.
+	Interval time.Duration
+}
+
+// Announce will send a gratuitous ARP (for IPv4 addresses) or an unsolicited
+// Neighbor Advertisement (for IPv6 addresses) from the Interface, so that
+// peers on the other end of the TAP will update any cached hardware address
+// for the IP.
+//
+// The first announcement is sent before Announce returns, any repeats (as
+// configured by the AnnounceOptions passed to New) are sent in the
+// background until the Interface is closed.
+//
+// Frames are transmitted by the kernel out of the TAP, not written to the
+// TAP like Write, so this requires the same permissions as opening a raw
+// socket on the host.
+func (i Interface) Announce(ip net.IP) error {
+	mac, err := i.HardwareAddr()
+	if err != nil {
+		return err
+	}
+
+	var frame *ethernet.Frame
+	if ip4 := ip.To4(); len(ip4) == net.IPv4len {
+		frame, err = gratuitousARP(mac, ip4)
+	} else {
+		frame, err = unsolicitedNA(mac, ip, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf, err := frame.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := i.transmit(buf); err != nil {
+		return err
+	}
+
+	count, interval := i.announce.Count, i.announce.Interval
+	if count <= 1 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for n := 1; n < count; n++ {
+			select {
+			case <-i.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := i.transmit(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// autoAnnounce will Announce the IPs if AnnounceOptions.Automatic is set.
+// The change that triggered this has already been made by the time this is
+// called, so errors are dropped rather than failing the change.
+func (i Interface) autoAnnounce(ips ...net.IP) {
+	if !i.announce.Automatic {
+		return
+	}
+	// Frames can't be sent while the link is down; SetUp will call us
+	// again once it's up.
+	if up, err := i.IsUp(); err != nil || !up {
+		return
+	}
+
+	for _, ip := range ips {
+		if ip4 := ip.To4(); len(ip4) == net.IPv4len {
+			i.Announce(ip4)
+			continue
+		}
+		// IPv6 addresses are tentative until DAD is done, and
+		// announcing a tentative address would defeat the point of DAD.
+		go func(ip net.IP) {
+			if i.waitDAD(ip) {
+				i.Announce(ip)
+			}
+		}(ip)
+	}
+}
+
+// autoAnnounceAll will autoAnnounce every address currently on the
+// Interface.
+func (i Interface) autoAnnounceAll() {
+	if !i.announce.Automatic {
+		return
+	}
+	netif, err := net.InterfaceByIndex(i.ps.index())
+	if err != nil {
+		return
+	}
+	addrs, err := netif.Addrs()
+	if err != nil {
+		return
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	i.autoAnnounce(ips...)
+}
+
+// vim: foldmethod=marker
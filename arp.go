@@ -0,0 +1,126 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// arpOperation is the ARP opcode, from RFC 826.
+type arpOperation uint16
+
+const (
+	arpOperationRequest arpOperation = 1
+	arpOperationReply   arpOperation = 2
+
+	// arpPacketLen is the size of an Ethernet / IPv4 ARP packet.
+	arpPacketLen = 28
+
+	arpHardwareEthernet uint16 = 1
+)
+
+// arpPacket is an ARP packet for IPv4 over Ethernet. No other hardware or
+// protocol types are supported.
+type arpPacket struct {
+	Operation          arpOperation
+	SenderHardwareAddr net.HardwareAddr
+	SenderIP           net.IP
+	TargetHardwareAddr net.HardwareAddr
+	TargetIP           net.IP
+}
+
+// MarshalBinary will encode the ARP packet to its wire format.
+func (p *arpPacket) MarshalBinary() ([]byte, error) {
+	if len(p.SenderHardwareAddr) != 6 || len(p.TargetHardwareAddr) != 6 {
+		return nil, fmt.Errorf("tap: arp hardware addresses must be 48 bits")
+	}
+	sip, tip := p.SenderIP.To4(), p.TargetIP.To4()
+	if sip == nil || tip == nil {
+		return nil, fmt.Errorf("tap: arp protocol addresses must be IPv4")
+	}
+
+	b := make([]byte, arpPacketLen)
+	binary.BigEndian.PutUint16(b[0:2], arpHardwareEthernet)
+	binary.BigEndian.PutUint16(b[2:4], uint16(ethernet.EtherTypeIPv4))
+	b[4] = 6
+	b[5] = net.IPv4len
+	binary.BigEndian.PutUint16(b[6:8], uint16(p.Operation))
+	copy(b[8:14], p.SenderHardwareAddr)
+	copy(b[14:18], sip)
+	copy(b[18:24], p.TargetHardwareAddr)
+	copy(b[24:28], tip)
+	return b, nil
+}
+
+// UnmarshalBinary will decode an ARP packet from its wire format. Any
+// trailing bytes (such as Ethernet padding) are ignored.
+func (p *arpPacket) UnmarshalBinary(b []byte) error {
+	if len(b) < arpPacketLen {
+		return io.ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(b[0:2]) != arpHardwareEthernet ||
+		binary.BigEndian.Uint16(b[2:4]) != uint16(ethernet.EtherTypeIPv4) ||
+		b[4] != 6 || b[5] != net.IPv4len {
+		return fmt.Errorf("tap: arp packet is not IPv4 over Ethernet")
+	}
+
+	p.Operation = arpOperation(binary.BigEndian.Uint16(b[6:8]))
+	p.SenderHardwareAddr = append(net.HardwareAddr{}, b[8:14]...)
+	p.SenderIP = append(net.IP{}, b[14:18]...)
+	p.TargetHardwareAddr = append(net.HardwareAddr{}, b[18:24]...)
+	p.TargetIP = append(net.IP{}, b[24:28]...)
+	return nil
+}
+
+// frame will wrap the ARP packet in an Ethernet Frame from the sender
+// hardware address to the provided destination.
+func (p *arpPacket) frame(dst net.HardwareAddr) (*ethernet.Frame, error) {
+	payload, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &ethernet.Frame{
+		Destination: dst,
+		Source:      p.SenderHardwareAddr,
+		EtherType:   ethernet.EtherTypeARP,
+		Payload:     payload,
+	}, nil
+}
+
+// gratuitousARP will build a broadcast gratuitous ARP request announcing
+// that the IP is at the provided hardware address.
+func gratuitousARP(mac net.HardwareAddr, ip net.IP) (*ethernet.Frame, error) {
+	p := arpPacket{
+		Operation:          arpOperationRequest,
+		SenderHardwareAddr: mac,
+		SenderIP:           ip,
+		TargetHardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, 0},
+		TargetIP:           ip,
+	}
+	return p.frame(ethernet.Broadcast)
+}
+
+// vim: foldmethod=marker
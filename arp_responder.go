@@ -0,0 +1,189 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// ARPResponderOptions contains configuration for an ARPResponder.
+type ARPResponderOptions struct {
+	// Gratuitous will send a gratuitous ARP whenever an entry is added to
+	// the table, or an existing entry's hardware address is changed.
+	Gratuitous bool
+}
+
+// ARPResponder will answer ARP requests arriving on a TAP for a table of
+// IPv4 addresses owned by userspace. The table may be changed at any time.
+//
+// ARPResponder is itself a FrameReadWriter. Calls to Read will answer any
+// ARP requests for addresses in the table, and return every other Frame
+// (including any ARP traffic not handled here) to the caller.
+type ARPResponder struct {
+	frames FrameReadWriter
+	opts   ARPResponderOptions
+
+	mu    sync.RWMutex
+	table map[[net.IPv4len]byte]net.HardwareAddr
+}
+
+// NewARPResponder will create a new ARPResponder reading and writing Frames
+// to the provided FrameReadWriter, which is usually an Interface.
+func NewARPResponder(frames FrameReadWriter, opts ARPResponderOptions) *ARPResponder {
+	return &ARPResponder{
+		frames: frames,
+		opts:   opts,
+		table:  map[[net.IPv4len]byte]net.HardwareAddr{},
+	}
+}
+
+func arpTableKey(ip net.IP) ([net.IPv4len]byte, error) {
+	var key [net.IPv4len]byte
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return key, fmt.Errorf("tap: %s is not an IPv4 address", ip)
+	}
+	copy(key[:], ip4)
+	return key, nil
+}
+
+// Set will answer ARP requests for the IP with the provided hardware
+// address, replacing any existing entry for the IP.
+func (r *ARPResponder) Set(ip net.IP, mac net.HardwareAddr) error {
+	key, err := arpTableKey(ip)
+	if err != nil {
+		return err
+	}
+	if len(mac) != 6 {
+		return fmt.Errorf("tap: arp hardware addresses must be 48 bits")
+	}
+
+	r.mu.Lock()
+	old, ok := r.table[key]
+	r.table[key] = append(net.HardwareAddr{}, mac...)
+	r.mu.Unlock()
+
+	if !r.opts.Gratuitous || (ok && bytes.Equal(old, mac)) {
+		return nil
+	}
+
+	frame, err := gratuitousARP(mac, ip)
+	if err != nil {
+		return err
+	}
+	return r.frames.Write(frame)
+}
+
+// Remove will stop answering ARP requests for the IP.
+func (r *ARPResponder) Remove(ip net.IP) {
+	key, err := arpTableKey(ip)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.table, key)
+}
+
+// Lookup will return the hardware address ARP requests for the IP are
+// being answered with, if any.
+func (r *ARPResponder) Lookup(ip net.IP) (net.HardwareAddr, bool) {
+	key, err := arpTableKey(ip)
+	if err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mac, ok := r.table[key]
+	return mac, ok
+}
+
+// Read will read Frames from the underlying FrameReadWriter, answering any
+// ARP requests for addresses in the table, and returning the next Frame
+// that was not handled.
+func (r *ARPResponder) Read() (*ethernet.Frame, error) {
+	for {
+		frame, err := r.frames.Read()
+		if err != nil {
+			return nil, err
+		}
+		handled, err := r.handle(frame)
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			return frame, nil
+		}
+	}
+}
+
+// Write will write the Frame to the underlying FrameReadWriter.
+func (r *ARPResponder) Write(frame *ethernet.Frame) error {
+	return r.frames.Write(frame)
+}
+
+// handle will reply to the Frame if it's an ARP request for an address in
+// the table, returning true if the Frame was consumed.
+func (r *ARPResponder) handle(frame *ethernet.Frame) (bool, error) {
+	if frame.EtherType != ethernet.EtherTypeARP {
+		return false, nil
+	}
+
+	var req arpPacket
+	if err := req.UnmarshalBinary(frame.Payload); err != nil {
+		// Not something we understand; let the caller deal with it.
+		return false, nil
+	}
+	if req.Operation != arpOperationRequest {
+		return false, nil
+	}
+	if req.SenderIP.Equal(req.TargetIP) {
+		// Gratuitous ARP from the peer; this isn't a question.
+		return false, nil
+	}
+
+	mac, ok := r.Lookup(req.TargetIP)
+	if !ok {
+		return false, nil
+	}
+
+	reply := arpPacket{
+		Operation:          arpOperationReply,
+		SenderHardwareAddr: mac,
+		SenderIP:           req.TargetIP,
+		TargetHardwareAddr: req.SenderHardwareAddr,
+		TargetIP:           req.SenderIP,
+	}
+	replyFrame, err := reply.frame(req.SenderHardwareAddr)
+	if err != nil {
+		return true, err
+	}
+	replyFrame.ServiceVLAN = frame.ServiceVLAN
+	replyFrame.VLAN = frame.VLAN
+	return true, r.frames.Write(replyFrame)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,265 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/ethernet"
+)
+
+var (
+	testMAC     = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	testPeerMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+func TestARPPacket(t *testing.T) {
+	request := arpPacket{
+		Operation:          arpOperationRequest,
+		SenderHardwareAddr: testPeerMAC,
+		SenderIP:           net.IPv4(192, 0, 2, 2).To4(),
+		TargetHardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, 0},
+		TargetIP:           net.IPv4(192, 0, 2, 1).To4(),
+	}
+	wire := []byte{
+		0x00, 0x01, // Ethernet
+		0x08, 0x00, // IPv4
+		6, 4,
+		0x00, 0x01, // request
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x02,
+		192, 0, 2, 2,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		192, 0, 2, 1,
+	}
+
+	b, err := request.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wire) {
+		t.Fatalf("MarshalBinary = %x, want %x", b, wire)
+	}
+
+	for _, tc := range []struct {
+		name string
+		wire []byte
+		err  bool
+	}{
+		{"exact", wire, false},
+		{"padded", append(append([]byte{}, wire...), make([]byte, 18)...), false},
+		{"short", wire[:arpPacketLen-1], true},
+		{"not ethernet", append([]byte{0x00, 0x06}, wire[2:]...), true},
+		{"not ipv4", append([]byte{0x00, 0x01, 0x86, 0xdd}, wire[4:]...), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got arpPacket
+			err := got.UnmarshalBinary(tc.wire)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, request) {
+				t.Fatalf("got %+v, want %+v", got, request)
+			}
+		})
+	}
+
+	if err := (&arpPacket{}).UnmarshalBinary(nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("empty packet: %v", err)
+	}
+}
+
+func TestARPPacketMarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		packet arpPacket
+	}{
+		{"short mac", arpPacket{
+			SenderHardwareAddr: testMAC[:4],
+			TargetHardwareAddr: testPeerMAC,
+			SenderIP:           net.IPv4(192, 0, 2, 1),
+			TargetIP:           net.IPv4(192, 0, 2, 2),
+		}},
+		{"ipv6", arpPacket{
+			SenderHardwareAddr: testMAC,
+			TargetHardwareAddr: testPeerMAC,
+			SenderIP:           net.ParseIP("2001:db8::1"),
+			TargetIP:           net.IPv4(192, 0, 2, 2),
+		}},
+	} {
+		if _, err := tc.packet.MarshalBinary(); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+// arpFrame will build an ARP Frame from the sender of the packet.
+func arpFrame(t *testing.T, p arpPacket, dst net.HardwareAddr) *ethernet.Frame {
+	t.Helper()
+	frame, err := p.frame(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestARPResponder(t *testing.T) {
+	var (
+		ours   = net.IPv4(192, 0, 2, 1).To4()
+		theirs = net.IPv4(192, 0, 2, 2).To4()
+		other  = net.IPv4(192, 0, 2, 3).To4()
+		zero   = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+	)
+	request := func(target net.IP) arpPacket {
+		return arpPacket{
+			Operation:          arpOperationRequest,
+			SenderHardwareAddr: testPeerMAC,
+			SenderIP:           theirs,
+			TargetHardwareAddr: zero,
+			TargetIP:           target,
+		}
+	}
+
+	tagged := arpFrame(t, request(ours), ethernet.Broadcast)
+	tagged.VLAN = &ethernet.VLAN{ID: 100}
+
+	for _, tc := range []struct {
+		name    string
+		frame   *ethernet.Frame
+		handled bool
+	}{
+		{"request", arpFrame(t, request(ours), ethernet.Broadcast), true},
+		{"tagged request", tagged, true},
+		{"someone else", arpFrame(t, request(other), ethernet.Broadcast), false},
+		{"gratuitous", arpFrame(t, arpPacket{
+			Operation:          arpOperationRequest,
+			SenderHardwareAddr: testPeerMAC,
+			SenderIP:           theirs,
+			TargetHardwareAddr: zero,
+			TargetIP:           theirs,
+		}, ethernet.Broadcast), false},
+		{"reply", arpFrame(t, arpPacket{
+			Operation:          arpOperationReply,
+			SenderHardwareAddr: testPeerMAC,
+			SenderIP:           theirs,
+			TargetHardwareAddr: testMAC,
+			TargetIP:           ours,
+		}, testMAC), false},
+		{"ipv4", &ethernet.Frame{
+			Destination: testMAC,
+			Source:      testPeerMAC,
+			EtherType:   ethernet.EtherTypeIPv4,
+			Payload:     make([]byte, 20),
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frames := newTestFrames(tc.frame)
+			r := NewARPResponder(frames, ARPResponderOptions{})
+			if err := r.Set(ours, testMAC); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.Read()
+			if tc.handled {
+				if err != io.EOF {
+					t.Fatalf("Read() = %v, %v; want the request to be consumed", got, err)
+				}
+			} else if got != tc.frame {
+				t.Fatalf("Read() = %v, %v; want the frame passed through", got, err)
+			}
+
+			written := frames.Written()
+			if !tc.handled {
+				if len(written) != 0 {
+					t.Fatalf("unexpected reply: %+v", written)
+				}
+				return
+			}
+			if len(written) != 1 {
+				t.Fatalf("wrote %d frames, want 1 reply", len(written))
+			}
+			reply := written[0]
+			if !bytes.Equal(reply.Destination, testPeerMAC) || !bytes.Equal(reply.Source, testMAC) {
+				t.Errorf("reply is from %s to %s", reply.Source, reply.Destination)
+			}
+			if !reflect.DeepEqual(reply.VLAN, tc.frame.VLAN) {
+				t.Errorf("reply VLAN is %+v, want %+v", reply.VLAN, tc.frame.VLAN)
+			}
+			var p arpPacket
+			if err := p.UnmarshalBinary(reply.Payload); err != nil {
+				t.Fatal(err)
+			}
+			want := arpPacket{
+				Operation:          arpOperationReply,
+				SenderHardwareAddr: testMAC,
+				SenderIP:           ours,
+				TargetHardwareAddr: testPeerMAC,
+				TargetIP:           theirs,
+			}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("reply is %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestARPResponderTable(t *testing.T) {
+	frames := newTestFrames()
+	r := NewARPResponder(frames, ARPResponderOptions{Gratuitous: true})
+	ip := net.IPv4(192, 0, 2, 1)
+
+	if err := r.Set(net.ParseIP("2001:db8::1"), testMAC); err == nil {
+		t.Error("Set accepted an IPv6 address")
+	}
+	if err := r.Set(ip, testMAC[:5]); err == nil {
+		t.Error("Set accepted a short hardware address")
+	}
+
+	for _, mac := range []net.HardwareAddr{testMAC, testMAC, testPeerMAC} {
+		if err := r.Set(ip, mac); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Setting the same address again isn't a change worth announcing.
+	if n := len(frames.Written()); n != 2 {
+		t.Fatalf("sent %d gratuitous ARPs, want 2", n)
+	}
+
+	if mac, ok := r.Lookup(ip); !ok || !bytes.Equal(mac, testPeerMAC) {
+		t.Fatalf("Lookup = %s, %t", mac, ok)
+	}
+	r.Remove(ip)
+	if _, ok := r.Lookup(ip); ok {
+		t.Fatal("entry is still there after Remove")
+	}
+}
+
+// vim: foldmethod=marker
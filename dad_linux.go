@@ -0,0 +1,76 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// waitDAD will wait for Duplicate Address Detection of the IPv6 address to
+// finish, returning false if it failed, took too long, the address was
+// removed, or the Interface was closed.
+func (i Interface) waitDAD(ip net.IP) bool {
+	var (
+		ticker  = time.NewTicker(100 * time.Millisecond)
+		timeout = time.After(10 * time.Second)
+	)
+	defer ticker.Stop()
+
+	for {
+		addrs, err := netlink.AddrList(i.ps.link(), netlink.FAMILY_V6)
+		if err != nil {
+			return false
+		}
+
+		found := false
+		for _, addr := range addrs {
+			if !addr.IP.Equal(ip) {
+				continue
+			}
+			if addr.Flags&unix.IFA_F_DADFAILED != 0 {
+				return false
+			}
+			if addr.Flags&unix.IFA_F_TENTATIVE == 0 {
+				return true
+			}
+			found = true
+		}
+		if !found {
+			return false
+		}
+
+		select {
+		case <-i.ctx.Done():
+			return false
+		case <-timeout:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,275 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Names of commonly toggled features, as used by the kernel (and shown by
+// `ethtool -k`). Features returns the full set supported by the Interface.
+const (
+	FeatureTxChecksum    = "tx-checksum-ip-generic"
+	FeatureScatterGather = "tx-scatter-gather"
+	FeatureTSO           = "tx-tcp-segmentation"
+	FeatureTSO6          = "tx-tcp6-segmentation"
+	FeatureGSO           = "tx-generic-segmentation"
+	FeatureGRO           = "rx-gro"
+)
+
+const (
+	// ethSSFeatures is ETH_SS_FEATURES, the string set of feature names.
+	ethSSFeatures = 4
+
+	// ethGStringLen is ETH_GSTRING_LEN.
+	ethGStringLen = 32
+)
+
+// DriverInfo is the information about the driver of the Interface, as
+// shown by `ethtool -i`.
+type DriverInfo struct {
+	Driver          string
+	Version         string
+	FirmwareVersion string
+	BusInfo         string
+}
+
+// Feature is an offload (or other) feature of the Interface, as shown by
+// `ethtool -k`.
+type Feature struct {
+	Name string
+
+	// Active is true if the feature is currently enabled, and Requested
+	// is true if it has been asked to be enabled.
+	Active    bool
+	Requested bool
+
+	// Changeable is false if the feature is fixed by the driver.
+	Changeable bool
+}
+
+// ifreqData is an ABI compatible version of ifreq as seen in
+// `netdevice(7)`, with the ifr_data member of the union.
+type ifreqData struct {
+	Name [unix.IFNAMSIZ]byte
+	Data unsafe.Pointer
+
+	_ [40 - unix.IFNAMSIZ - 8]uint8
+}
+
+// ethtool will issue a SIOCETHTOOL ioctl, with data pointing at the
+// ethtool command struct.
+func (i Interface) ethtool(data unsafe.Pointer) error {
+	req := ifreqData{Name: i.ifname(), Data: data}
+
+	// The ioctl finds the interface by name in the namespace of the socket,
+	// so the socket has to be created in the Interface's namespace.
+	return i.ps.inNetns(func() error {
+		file, err := afInet()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return ioctl(unix.SIOCETHTOOL, file.Fd(), uintptr(unsafe.Pointer(&req)))
+	})
+}
+
+// DriverInfo will return the information about the driver of the Interface.
+func (i Interface) DriverInfo() (*DriverInfo, error) {
+	var info *unix.EthtoolDrvinfo
+	err := i.ps.inNetns(func() error {
+		file, err := afInet()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		info, err = unix.IoctlGetEthtoolDrvinfo(int(file.Fd()), i.Name())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DriverInfo{
+		Driver:          unix.ByteSliceToString(info.Driver[:]),
+		Version:         unix.ByteSliceToString(info.Version[:]),
+		FirmwareVersion: unix.ByteSliceToString(info.Fw_version[:]),
+		BusInfo:         unix.ByteSliceToString(info.Bus_info[:]),
+	}, nil
+}
+
+// featureNames will return the names of the features of the Interface, in
+// the order the kernel uses for feature bits.
+func (i Interface) featureNames() ([]string, error) {
+	native := nl.NativeEndian()
+
+	// struct ethtool_sset_info, with room for a single count.
+	info := make([]byte, 20)
+	native.PutUint32(info[0:], unix.ETHTOOL_GSSET_INFO)
+	native.PutUint64(info[8:], 1<<ethSSFeatures)
+	if err := i.ethtool(unsafe.Pointer(&info[0])); err != nil {
+		return nil, err
+	}
+	if native.Uint64(info[8:]) == 0 {
+		return nil, fmt.Errorf("tap: interface has no feature names")
+	}
+	count := native.Uint32(info[16:])
+
+	// struct ethtool_gstrings, followed by count strings.
+	gstrings := make([]byte, 12+count*ethGStringLen)
+	native.PutUint32(gstrings[0:], unix.ETHTOOL_GSTRINGS)
+	native.PutUint32(gstrings[4:], ethSSFeatures)
+	native.PutUint32(gstrings[8:], count)
+	if err := i.ethtool(unsafe.Pointer(&gstrings[0])); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, count)
+	for j := range names {
+		name := gstrings[12+j*ethGStringLen : 12+(j+1)*ethGStringLen]
+		names[j] = string(bytes.TrimRight(name, "\x00"))
+	}
+	return names, nil
+}
+
+// featureBlocks will return the number of 32 bit blocks needed to hold
+// count feature bits.
+func featureBlocks(count int) int {
+	return (count + 31) / 32
+}
+
+// Features will return the features of the Interface, such as checksum
+// and segmentation offloads.
+func (i Interface) Features() ([]Feature, error) {
+	names, err := i.featureNames()
+	if err != nil {
+		return nil, err
+	}
+
+	// struct ethtool_gfeatures, followed by an ethtool_get_features_block
+	// (available, requested, active and never_changed) per 32 features.
+	native := nl.NativeEndian()
+	blocks := featureBlocks(len(names))
+	buf := make([]byte, 8+blocks*16)
+	native.PutUint32(buf[0:], unix.ETHTOOL_GFEATURES)
+	native.PutUint32(buf[4:], uint32(blocks))
+	if err := i.ethtool(unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+
+	return decodeFeatures(names, buf[8:]), nil
+}
+
+// decodeFeatures will decode the ethtool_get_features_blocks following an
+// ETHTOOL_GFEATURES reply, for the features with the provided names.
+func decodeFeatures(names []string, blocks []byte) []Feature {
+	native := nl.NativeEndian()
+	features := make([]Feature, len(names))
+	for j, name := range names {
+		var (
+			block        = blocks[(j/32)*16:]
+			bit          = uint32(1) << (j % 32)
+			available    = native.Uint32(block[0:])&bit != 0
+			requested    = native.Uint32(block[4:])&bit != 0
+			active       = native.Uint32(block[8:])&bit != 0
+			neverChanged = native.Uint32(block[12:])&bit != 0
+		)
+		features[j] = Feature{
+			Name:       name,
+			Active:     active,
+			Requested:  requested,
+			Changeable: available && !neverChanged,
+		}
+	}
+	return features
+}
+
+// SetFeatures will enable or disable the named features of the Interface.
+// Features which aren't in the map are left alone. The kernel may not be
+// able to enable a requested feature (for instance, if it depends on
+// another feature that is disabled), so check Active using Features if
+// that matters.
+func (i Interface) SetFeatures(features map[string]bool) error {
+	current, err := i.Features()
+	if err != nil {
+		return err
+	}
+
+	buf, err := encodeSetFeatures(current, features)
+	if err != nil {
+		return err
+	}
+	return i.ethtool(unsafe.Pointer(&buf[0]))
+}
+
+// encodeSetFeatures will build the ETHTOOL_SFEATURES request to change the
+// features in the map, given the current features of the Interface.
+func encodeSetFeatures(current []Feature, features map[string]bool) ([]byte, error) {
+	for name := range features {
+		if !hasFeature(current, name) {
+			return nil, fmt.Errorf("tap: unknown feature %s", name)
+		}
+	}
+
+	// struct ethtool_sfeatures, followed by an ethtool_set_features_block
+	// (valid and requested) per 32 features.
+	native := nl.NativeEndian()
+	blocks := featureBlocks(len(current))
+	buf := make([]byte, 8+blocks*8)
+	native.PutUint32(buf[0:], unix.ETHTOOL_SFEATURES)
+	native.PutUint32(buf[4:], uint32(blocks))
+
+	for j, feature := range current {
+		on, ok := features[feature.Name]
+		if !ok {
+			continue
+		}
+		if !feature.Changeable {
+			return nil, fmt.Errorf("tap: feature %s can't be changed", feature.Name)
+		}
+
+		block := buf[8+(j/32)*8:]
+		bit := uint32(1) << (j % 32)
+		native.PutUint32(block[0:], native.Uint32(block[0:])|bit)
+		if on {
+			native.PutUint32(block[4:], native.Uint32(block[4:])|bit)
+		}
+	}
+	return buf, nil
+}
+
+func hasFeature(features []Feature, name string) bool {
+	for _, feature := range features {
+		if feature.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,171 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestIfreqDataLayout(t *testing.T) {
+	// struct ifreq is 40 bytes on 64 bit Linux, with ifr_data right after
+	// the name.
+	var req ifreqData
+	if size := unsafe.Sizeof(req); size != 40 {
+		t.Errorf("ifreqData is %d bytes, want 40", size)
+	}
+	if offset := unsafe.Offsetof(req.Data); offset != unix.IFNAMSIZ {
+		t.Errorf("ifreqData.Data is at %d, want %d", offset, unix.IFNAMSIZ)
+	}
+}
+
+func TestFeatureBlocks(t *testing.T) {
+	for _, tc := range []struct{ count, blocks int }{
+		{0, 0}, {1, 1}, {32, 1}, {33, 2}, {64, 2}, {65, 3},
+	} {
+		if got := featureBlocks(tc.count); got != tc.blocks {
+			t.Errorf("featureBlocks(%d) = %d, want %d", tc.count, got, tc.blocks)
+		}
+	}
+}
+
+// featureNamesForTest will return count distinct feature names.
+func featureNamesForTest(count int) []string {
+	names := make([]string, count)
+	for j := range names {
+		names[j] = string(rune('a'+j%26)) + string(rune('a'+j/26))
+	}
+	return names
+}
+
+func TestDecodeFeatures(t *testing.T) {
+	native := nl.NativeEndian()
+	names := featureNamesForTest(34)
+
+	// Two ethtool_get_features_blocks of available, requested, active
+	// and never_changed.
+	blocks := make([]byte, 32)
+	put := func(block, field int, bits uint32) {
+		native.PutUint32(blocks[block*16+field*4:], bits)
+	}
+	put(0, 0, 1<<0|1<<1|1<<31) // available
+	put(0, 1, 1<<0|1<<31)      // requested
+	put(0, 2, 1<<0)            // active
+	put(0, 3, 1<<31)           // never_changed
+	put(1, 0, 1<<1)            // available: feature 33
+	put(1, 2, 1<<1)            // active: feature 33
+
+	features := decodeFeatures(names, blocks)
+	for _, tc := range []struct {
+		index int
+		want  Feature
+	}{
+		{0, Feature{Name: names[0], Active: true, Requested: true, Changeable: true}},
+		{1, Feature{Name: names[1], Changeable: true}},
+		{2, Feature{Name: names[2]}},
+		{31, Feature{Name: names[31], Requested: true}},
+		{32, Feature{Name: names[32]}},
+		{33, Feature{Name: names[33], Active: true, Changeable: true}},
+	} {
+		if got := features[tc.index]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("feature %d is %+v, want %+v", tc.index, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeSetFeatures(t *testing.T) {
+	native := nl.NativeEndian()
+	names := featureNamesForTest(40)
+	current := make([]Feature, len(names))
+	for j, name := range names {
+		current[j] = Feature{Name: name, Changeable: j != 5}
+	}
+
+	buf, err := encodeSetFeatures(current, map[string]bool{
+		names[0]:  true,
+		names[3]:  false,
+		names[35]: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// struct ethtool_sfeatures, then two blocks of valid and requested.
+	want := make([]byte, 8+2*8)
+	native.PutUint32(want[0:], unix.ETHTOOL_SFEATURES)
+	native.PutUint32(want[4:], 2)
+	native.PutUint32(want[8:], 1<<0|1<<3)
+	native.PutUint32(want[12:], 1<<0)
+	native.PutUint32(want[16:], 1<<3)
+	native.PutUint32(want[20:], 1<<3)
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("got %x, want %x", buf, want)
+	}
+
+	for _, features := range []map[string]bool{
+		{"nope": true},
+		{names[5]: true},
+	} {
+		if _, err := encodeSetFeatures(current, features); err == nil {
+			t.Errorf("%v: expected an error", features)
+		}
+	}
+}
+
+func TestEthtool(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+
+	info, err := iface.DriverInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Driver != "tun" {
+		t.Errorf("driver is %q, want tun", info.Driver)
+	}
+
+	features, err := iface.Features()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasFeature(features, FeatureTSO) {
+		t.Fatalf("%s is missing from %+v", FeatureTSO, features)
+	}
+}
+
+func TestEthtoolInNetns(t *testing.T) {
+	iface := newTestInterfaceInNetns(t, Options{})
+
+	if _, err := iface.DriverInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := iface.Features(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: foldmethod=marker
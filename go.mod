@@ -5,7 +5,6 @@ go 1.19
 require (
 	github.com/mdlayher/ethernet v0.0.0-20220221185849-529eae5b6118
 	github.com/vishvananda/netlink v1.1.0
+	github.com/vishvananda/netns v0.0.4
 	golang.org/x/sys v0.8.0
 )
-
-require github.com/vishvananda/netns v0.0.4 // indirect
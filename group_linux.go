@@ -0,0 +1,174 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	// linkGroupsPath is the iproute2 mapping of link group numbers to
+	// names.
+	linkGroupsPath = "/etc/iproute2/group"
+)
+
+// linkGroupTable is the mapping between link group names and numbers.
+type linkGroupTable struct {
+	ids   map[string]uint32
+	names map[uint32]string
+}
+
+// linkGroups will return the mapping of link group names and numbers from
+// linkGroupsPath. Group 0 is always named "default", even if the file is
+// missing.
+func linkGroups() (*linkGroupTable, error) {
+	file, err := os.Open(linkGroupsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return parseLinkGroups(strings.NewReader(""))
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return parseLinkGroups(file)
+}
+
+// parseLinkGroups will parse a file in the format of /etc/iproute2/group.
+// If a name or number is listed more than once, the first entry wins, so
+// that the name returned for a number doesn't change between calls.
+func parseLinkGroups(r io.Reader) (*linkGroupTable, error) {
+	groups := &linkGroupTable{
+		ids:   map[string]uint32{"default": 0},
+		names: map[uint32]string{0: "default"},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		id64, err := strconv.ParseUint(fields[0], 0, 32)
+		if err != nil {
+			continue
+		}
+		id, name := uint32(id64), fields[1]
+		if _, ok := groups.ids[name]; !ok {
+			groups.ids[name] = id
+		}
+		// Only name the group if the name resolves back to it, so that
+		// Groups can be passed to RemoveGroup.
+		if _, ok := groups.names[id]; !ok && groups.ids[name] == id {
+			groups.names[id] = name
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// linkGroup will resolve a link group name (or number) to the group number.
+func linkGroup(group string) (uint32, error) {
+	if group == "" {
+		return 0, fmt.Errorf("tap: interface group is empty")
+	}
+	if id, err := strconv.ParseUint(group, 0, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	groups, err := linkGroups()
+	if err != nil {
+		return 0, err
+	}
+	id, ok := groups.ids[group]
+	if !ok {
+		return 0, fmt.Errorf("tap: unknown interface group %q", group)
+	}
+	return id, nil
+}
+
+// AddGroup will add the provided group name to the underlying TAP
+// interface. The name is resolved using /etc/iproute2/group, or may be
+// the group number.
+//
+// Unlike OpenBSD, a Linux interface is in exactly one group, so this will
+// replace the existing group.
+func (i Interface) AddGroup(group string) error {
+	id, err := linkGroup(group)
+	if err != nil {
+		return err
+	}
+	iface := i.ps.link()
+	return netlink.LinkSetGroup(iface, int(id))
+}
+
+// RemoveGroup will remove the group name from the TAP interface, putting
+// it back in the "default" group.
+func (i Interface) RemoveGroup(group string) error {
+	id, err := linkGroup(group)
+	if err != nil {
+		return err
+	}
+	link, err := netlink.LinkByIndex(i.ps.index())
+	if err != nil {
+		return err
+	}
+	if link.Attrs().Group != id {
+		return fmt.Errorf("tap: interface is not in group %q", group)
+	}
+	return netlink.LinkSetGroup(link, 0)
+}
+
+// Groups will return the names of the groups the TAP interface is in. On
+// Linux this is always exactly one group, which will be the group number
+// if it has no name in /etc/iproute2/group.
+func (i Interface) Groups() ([]string, error) {
+	link, err := netlink.LinkByIndex(i.ps.index())
+	if err != nil {
+		return nil, err
+	}
+	id := link.Attrs().Group
+
+	groups, err := linkGroups()
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := groups.names[id]; ok {
+		return []string{name}, nil
+	}
+	return []string{strconv.FormatUint(uint64(id), 10)}, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,91 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLinkGroups(t *testing.T) {
+	const groupFile = `# /etc/iproute2/group
+0	default
+1	tenants # trailing comment
+1	customers
+0x10	uplinks
+bogus	nope
+2
+3	tenants
+`
+	groups, err := parseLinkGroups(strings.NewReader(groupFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		id   uint32
+		ok   bool
+	}{
+		{"default", 0, true},
+		{"tenants", 1, true},
+		{"customers", 1, true},
+		{"uplinks", 16, true},
+		{"nope", 0, false},
+		{"#", 0, false},
+	} {
+		id, ok := groups.ids[tc.name]
+		if ok != tc.ok || id != tc.id {
+			t.Errorf("ids[%q] = %d, %t; want %d, %t", tc.name, id, ok, tc.id, tc.ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		id   uint32
+		name string
+	}{
+		{0, "default"},
+		// The first name in the file wins over aliases.
+		{1, "tenants"},
+		{16, "uplinks"},
+		{2, ""},
+		// "tenants" is already group 1.
+		{3, ""},
+	} {
+		if name := groups.names[tc.id]; name != tc.name {
+			t.Errorf("names[%d] = %q, want %q", tc.id, name, tc.name)
+		}
+	}
+}
+
+func TestParseLinkGroupsEmpty(t *testing.T) {
+	groups, err := parseLinkGroups(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups.ids["default"] != 0 || groups.names[0] != "default" {
+		t.Fatalf("default group is missing: %+v", groups)
+	}
+}
+
+// vim: foldmethod=marker
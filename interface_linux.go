@@ -23,30 +23,106 @@
 package tap
 
 import (
+	"fmt"
 	"net"
+	"syscall"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
 )
 
 // SetHardwareAddr will set the link state to up or down.
 func (i Interface) SetHardwareAddr(addr net.HardwareAddr) error {
-	iface := i.ps.netif
-	return netlink.LinkSetHardwareAddr(iface, addr)
+	iface := i.ps.link()
+	if err := netlink.LinkSetHardwareAddr(iface, addr); err != nil {
+		return err
+	}
+	i.autoAnnounceAll()
+	return nil
 }
 
 // SetUp will set the link state to up or down.
 func (i Interface) SetUp(updown bool) error {
-	iface := i.ps.netif
-	if updown {
-		return netlink.LinkSetUp(iface)
+	iface := i.ps.link()
+	if !updown {
+		return netlink.LinkSetDown(iface)
+	}
+	if err := netlink.LinkSetUp(iface); err != nil {
+		return err
 	}
-	return netlink.LinkSetDown(iface)
+	i.autoAnnounceAll()
+	return nil
 }
 
 // SetMTU will set the MTU for the created TAP interface.
 func (i Interface) SetMTU(sz uint) error {
-	iface := i.ps.netif
+	iface := i.ps.link()
 	return netlink.LinkSetMTU(iface, int(sz))
 }
 
+// State will return the current state of the Interface from the kernel.
+func (i Interface) State() (LinkState, error) {
+	link, err := netlink.LinkByIndex(i.ps.index())
+	if err != nil {
+		return LinkState{}, err
+	}
+	return newLinkState(link), nil
+}
+
+// Refresh will update the state of the Interface cached at creation time
+// with the current state from the kernel. Most callers should use State
+// (or one of the other getters), which always query the kernel.
+func (i Interface) Refresh() error {
+	link, err := netlink.LinkByIndex(i.ps.index())
+	if err != nil {
+		return err
+	}
+	// The cached netlink.Link is shared by every copy of this Interface,
+	// and read from background goroutines, so swap in the new one rather
+	// than update it in place.
+	i.ps.netif.Store(&link)
+	return nil
+}
+
+// rename will change the name of the link in the kernel.
+func (i Interface) rename(name string) error {
+	iface := i.ps.link()
+	return netlink.LinkSetName(iface, name)
+}
+
+// SetDescription will set the network interface description (IFLA_IFALIAS)
+// to the provided string. An empty string will clear the description.
+func (i Interface) SetDescription(descr string) error {
+	iface := i.ps.link()
+	return netlink.LinkSetAlias(iface, descr)
+}
+
+// Description will return the network interface description.
+func (i Interface) Description() (string, error) {
+	link, err := netlink.LinkByIndex(i.ps.index())
+	if err != nil {
+		return "", err
+	}
+	return link.Attrs().Alias, nil
+}
+
+// linkAttrs will fetch the link from the kernel, and return the raw netlink
+// attributes, for the things the netlink library doesn't know how to parse.
+func (i Interface) linkAttrs() ([]syscall.NetlinkRouteAttr, error) {
+	req := nl.NewNetlinkRequest(unix.RTM_GETLINK, unix.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(i.ps.index())
+	req.AddData(msg)
+
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWLINK)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 || len(msgs[0]) < unix.SizeofIfInfomsg {
+		return nil, fmt.Errorf("tap: unexpected RTM_GETLINK reply")
+	}
+	return nl.ParseRouteAttr(msgs[0][unix.SizeofIfInfomsg:])
+}
+
 // vim: foldmethod=marker
@@ -23,6 +23,7 @@
 package tap
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"syscall"
@@ -35,6 +36,10 @@ const (
 	// IFDESCRSIZE is the max size of the Description field on OpenBSD.
 	// Replace this once syscall or x/sys/unix grows IFDESCRSIZE.
 	IFDESCRSIZE int = 64
+
+	// SIOCSIFNAME will set the name of the network interface. Replace this
+	// once syscall or x/sys/unix grows SIOCSIFNAME.
+	SIOCSIFNAME uintptr = 0x802069db
 )
 
 type ifreqDescription struct {
@@ -55,7 +60,7 @@ func (i Interface) SetDescription(descr string) error {
 	copy(d[:], descr)
 
 	req := ifreqDescription{
-		Name:        i.name,
+		Name:        i.ifname(),
 		Description: &d,
 	}
 	if err := ioctl(syscall.SIOCSIFDESCR, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
@@ -64,6 +69,52 @@ func (i Interface) SetDescription(descr string) error {
 	return nil
 }
 
+// Description will return the network interface description.
+func (i Interface) Description() (string, error) {
+	file, err := afInet()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	d := [IFDESCRSIZE]byte{}
+	req := ifreqDescription{
+		Name:        i.ifname(),
+		Description: &d,
+	}
+	if err := ioctl(syscall.SIOCGIFDESCR, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(d[:], "\x00")), nil
+}
+
+type ifreqName struct {
+	Name    [syscall.IFNAMSIZ]byte
+	NewName *[syscall.IFNAMSIZ]byte
+
+	_ [8]byte
+}
+
+// rename will change the name of the network interface in the kernel.
+func (i Interface) rename(name string) error {
+	file, err := afInet()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	d := [syscall.IFNAMSIZ]byte{}
+	copy(d[:], name)
+
+	// The new name is pointed to by ifr_data, the same as the
+	// description is for SetDescription.
+	req := ifreqName{
+		Name:    i.ifname(),
+		NewName: &d,
+	}
+	return ioctl(SIOCSIFNAME, file.Fd(), uintptr(unsafe.Pointer(&req)))
+}
+
 type ifreqFlags struct {
 	Name  [syscall.IFNAMSIZ]byte
 	Flags uint16
@@ -71,26 +122,41 @@ type ifreqFlags struct {
 
 // SetUp will set the link state to up or down.
 func (i Interface) SetUp(updown bool) error {
+	if err := i.setFlag(syscall.IFF_UP, updown); err != nil {
+		return err
+	}
+	if updown {
+		i.autoAnnounceAll()
+	}
+	return nil
+}
+
+// SetPromisc will enable or disable promiscuous mode on the Interface.
+func (i Interface) SetPromisc(promisc bool) error {
+	return i.setFlag(syscall.IFF_PROMISC, promisc)
+}
+
+// setFlag will set or clear the interface flag.
+func (i Interface) setFlag(flag uint16, on bool) error {
 	file, err := afInet()
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	req := ifreqFlags{Name: i.name}
+	req := ifreqFlags{Name: i.ifname()}
 	if err := ioctl(syscall.SIOCGIFFLAGS, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
 		return err
 	}
 
-	if updown {
-		// Bring the iface up; so let's set the bit and let the syscall
-		// roll. This is a noop if the interface is already up.
-		req.Flags |= syscall.IFF_UP
+	if on {
+		// Set the bit and let the syscall roll. This is a noop if the
+		// flag is already set.
+		req.Flags |= flag
 	} else {
-		// Bring the interface down; let's create a bitmask of the flags
-		// without the IFF_UP flag, and send that back in. This is a
-		// noop if the interface is already down.
-		req.Flags &= (^uint16(syscall.IFF_UP))
+		// Let's create a bitmask of the flags without the flag, and send
+		// that back in. This is a noop if the flag is already clear.
+		req.Flags &= ^flag
 	}
 
 	if err := ioctl(syscall.SIOCSIFFLAGS, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
@@ -99,6 +165,57 @@ func (i Interface) SetUp(updown bool) error {
 	return nil
 }
 
+// State will return the current state of the Interface from the kernel.
+func (i Interface) State() (LinkState, error) {
+	netif, err := net.InterfaceByIndex(i.ps.index())
+	if err != nil {
+		return LinkState{}, err
+	}
+
+	file, err := afInet()
+	if err != nil {
+		return LinkState{}, err
+	}
+	defer file.Close()
+
+	req := ifreqFlags{Name: i.ifname()}
+	if err := ioctl(syscall.SIOCGIFFLAGS, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return LinkState{}, err
+	}
+
+	state := LinkState{
+		Name:         netif.Name,
+		Index:        netif.Index,
+		MTU:          netif.MTU,
+		HardwareAddr: netif.HardwareAddr,
+		Up:           req.Flags&syscall.IFF_UP != 0,
+		Carrier:      req.Flags&syscall.IFF_RUNNING != 0,
+		OperState:    OperStateDown,
+	}
+
+	// OpenBSD doesn't track RFC 2863 state, so we'll do our best with
+	// what we have.
+	if state.Up && state.Carrier {
+		state.OperState = OperStateUp
+	}
+	return state, nil
+}
+
+// Refresh will update the state of the Interface cached at creation time
+// with the current state from the kernel. Most callers should use State
+// (or one of the other getters), which always query the kernel.
+func (i Interface) Refresh() error {
+	netif, err := net.InterfaceByIndex(i.ps.index())
+	if err != nil {
+		return err
+	}
+	// The cached net.Interface is shared by every copy of this Interface,
+	// and read from background goroutines, so swap in the new one rather
+	// than update it in place.
+	i.ps.netif.Store(netif)
+	return nil
+}
+
 type ifreqMTU struct {
 	Name [syscall.IFNAMSIZ]byte
 	MTU  int32
@@ -111,7 +228,7 @@ func (i Interface) SetMTU(sz uint) error {
 		return err
 	}
 	defer file.Close()
-	req := ifreqMTU{Name: i.name, MTU: int32(sz)}
+	req := ifreqMTU{Name: i.ifname(), MTU: int32(sz)}
 	return ioctl(syscall.SIOCSIFMTU, file.Fd(), uintptr(unsafe.Pointer(&req)))
 }
 
@@ -129,7 +246,7 @@ func (i Interface) SetHardwareAddr(addr net.HardwareAddr) error {
 	}
 	defer file.Close()
 
-	req := ifreqLLADDR{Name: i.name}
+	req := ifreqLLADDR{Name: i.ifname()}
 	// Awkwardly, this is not a RawSockaddrDatalink, this is a RawSockaddr
 	// with a MAC in the payload. Soooo, cool cool. Let's just do that
 	// here.
@@ -156,7 +273,11 @@ func (i Interface) SetHardwareAddr(addr net.HardwareAddr) error {
 	buf := (*[14]byte)(unsafe.Pointer(&(req.Addr.Data[0])))
 	copy(buf[:6], addr)
 
-	return ioctl(syscall.SIOCSIFLLADDR, file.Fd(), uintptr(unsafe.Pointer(&req)))
+	if err := ioctl(syscall.SIOCSIFLLADDR, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return err
+	}
+	i.autoAnnounceAll()
+	return nil
 }
 
 type ifreqGroup struct {
@@ -189,7 +310,7 @@ func (i Interface) AddGroup(group string) error {
 		return err
 	}
 	defer file.Close()
-	req, err := newGroupReq(i.name, group)
+	req, err := newGroupReq(i.ifname(), group)
 	if err != nil {
 		return err
 	}
@@ -203,11 +324,50 @@ func (i Interface) RemoveGroup(group string) error {
 		return err
 	}
 	defer file.Close()
-	req, err := newGroupReq(i.name, group)
+	req, err := newGroupReq(i.ifname(), group)
 	if err != nil {
 		return err
 	}
 	return ioctl(syscall.SIOCDIFGROUP, file.Fd(), uintptr(unsafe.Pointer(req)))
 }
 
+// ifreqGroups is the ifgroupreq used with SIOCGIFGROUP, where the union
+// is the pointer to a list of ifg_req structs.
+type ifreqGroups struct {
+	Name     [syscall.IFNAMSIZ]byte
+	GroupLen uint64
+	Groups   *[syscall.IFNAMSIZ]byte
+	_        [8]byte
+}
+
+// Groups will return the names of the groups the TAP interface is in.
+func (i Interface) Groups() ([]string, error) {
+	file, err := afInet()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// First, ask the kernel how much space we need, and then ask again
+	// with a buffer that size. Each ifg_req is a group name.
+	req := ifreqGroups{Name: i.ifname()}
+	if err := ioctl(syscall.SIOCGIFGROUP, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return nil, err
+	}
+	groups := make([][syscall.IFNAMSIZ]byte, req.GroupLen/syscall.IFNAMSIZ)
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	req.Groups = &groups[0]
+	if err := ioctl(syscall.SIOCGIFGROUP, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, group := range groups[:req.GroupLen/syscall.IFNAMSIZ] {
+		ret = append(ret, string(bytes.TrimRight(group[:], "\x00")))
+	}
+	return ret, nil
+}
+
 // vim: foldmethod=marker
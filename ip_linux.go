@@ -34,12 +34,16 @@ import (
 // Under the hood this uses The Linux netlink interface to add the
 // IP Address to the interface.
 func (i Interface) AddAddr(ip net.IP, network *net.IPNet) error {
-	iface := i.ps.netif
+	iface := i.ps.link()
 	network.IP = ip
-	return netlink.AddrAdd(iface, &netlink.Addr{
+	if err := netlink.AddrAdd(iface, &netlink.Addr{
 		IPNet: network,
 		Peer:  network,
-	})
+	}); err != nil {
+		return err
+	}
+	i.autoAnnounce(ip)
+	return nil
 }
 
 // vim: foldmethod=marker
@@ -33,10 +33,19 @@ import (
 func (i Interface) AddAddr(ip net.IP, network *net.IPNet) error {
 	// netintro(4) - SIOCAIFADDR
 	network.IP = ip
+
+	var err error
 	if ip4 := ip.To4(); len(ip4) == net.IPv4len {
-		return i.addAddrIPv4(ip, network)
+		err = i.addAddrIPv4(ip, network)
+	} else {
+		err = i.addAddrIPv6(ip, network)
 	}
-	return i.addAddrIPv6(ip, network)
+	if err != nil {
+		return err
+	}
+
+	i.autoAnnounce(ip)
+	return nil
 }
 
 // IPv4 Address Support
@@ -78,7 +87,7 @@ func (i Interface) addAddrIPv4(ip net.IP, network *net.IPNet) error {
 
 	var ifar ifaliasreqIPv4
 
-	ifar.Name = i.name
+	ifar.Name = i.ifname()
 	ifar.Addr = rawSockaddrInet4(ip)
 	ifar.Mask = rawSockaddrInet4(net.IP(network.Mask))
 
@@ -125,7 +134,7 @@ func (i Interface) addAddrIPv6(ip net.IP, network *net.IPNet) error {
 	defer file.Close()
 
 	var ifar ifaliasreqIPv6
-	ifar.Name = i.name
+	ifar.Name = i.ifname()
 	ifar.Addr = rawSockaddrInet6(ip)
 	ifar.Mask = rawSockaddrInet6(net.IP(network.Mask))
 	ifar.Lifetime.ValidLifetime = 0xFFFFFFFF
@@ -0,0 +1,65 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"fmt"
+	"net"
+)
+
+// LinkLocalEUI64 will compute the IPv6 link-local address (fe80::/64) that
+// is derived from the provided 48 bit MAC address using the modified EUI-64
+// scheme from RFC 4291, Appendix A. This matches the address the kernel will
+// assign when the address generation mode is EUI-64.
+func LinkLocalEUI64(mac net.HardwareAddr) (net.IP, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("tap: EUI-64 requires a 48 bit MAC address")
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xfe
+	ip[1] = 0x80
+
+	// The interface identifier is the MAC with 0xFFFE stuffed into the
+	// middle, and the universal/local bit flipped.
+	ip[8] = mac[0] ^ 0x02
+	ip[9] = mac[1]
+	ip[10] = mac[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = mac[3]
+	ip[14] = mac[4]
+	ip[15] = mac[5]
+	return ip, nil
+}
+
+// LinkLocalEUI64 will return the EUI-64 IPv6 link-local address for the
+// current hardware address of the Interface. This will change if the
+// hardware address is changed with SetHardwareAddr.
+func (i Interface) LinkLocalEUI64() (net.IP, error) {
+	mac, err := i.HardwareAddr()
+	if err != nil {
+		return nil, err
+	}
+	return LinkLocalEUI64(mac)
+}
+
+// vim: foldmethod=marker
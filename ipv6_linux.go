@@ -0,0 +1,163 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// AddrGenMode controls how the Linux kernel will generate the IPv6
+// link-local address for the Interface.
+type AddrGenMode uint8
+
+const (
+	// AddrGenModeEUI64 will derive the link-local address from the hardware
+	// address of the Interface. This is the kernel default.
+	AddrGenModeEUI64 AddrGenMode = 0
+
+	// AddrGenModeNone will not generate a link-local address at all.
+	AddrGenModeNone AddrGenMode = 1
+
+	// AddrGenModeStablePrivacy will generate a stable link-local address
+	// using the method from RFC 7217, keyed on the stable secret.
+	AddrGenModeStablePrivacy AddrGenMode = 2
+
+	// AddrGenModeRandom will generate a random link-local address.
+	AddrGenModeRandom AddrGenMode = 3
+)
+
+// String will return the name of the AddrGenMode as used by iproute2.
+func (m AddrGenMode) String() string {
+	switch m {
+	case AddrGenModeEUI64:
+		return "eui64"
+	case AddrGenModeNone:
+		return "none"
+	case AddrGenModeStablePrivacy:
+		return "stable_secret"
+	case AddrGenModeRandom:
+		return "random"
+	default:
+		return fmt.Sprintf("AddrGenMode(%d)", uint8(m))
+	}
+}
+
+// SetAddrGenMode will set the IPv6 address generation mode of the Interface.
+// The new mode is used the next time the kernel generates a link-local
+// address, which is usually the next time the Interface is brought up.
+func (i Interface) SetAddrGenMode(mode AddrGenMode) error {
+	iface := i.ps.link()
+
+	req := nl.NewNetlinkRequest(unix.RTM_SETLINK, unix.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(iface.Attrs().Index)
+	req.AddData(msg)
+
+	afSpec := nl.NewRtAttr(unix.IFLA_AF_SPEC, nil)
+	inet6 := afSpec.AddRtAttr(unix.AF_INET6, nil)
+	inet6.AddRtAttr(unix.IFLA_INET6_ADDR_GEN_MODE, nl.Uint8Attr(uint8(mode)))
+	req.AddData(afSpec)
+
+	// AddrGenMode reads this back from the namespace the Interface was
+	// created in (as do the StableSecret sysctls), so set it there too.
+	return i.ps.inNetns(func() error {
+		_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+		return err
+	})
+}
+
+// AddrGenMode will return the current IPv6 address generation mode of the
+// Interface.
+func (i Interface) AddrGenMode() (AddrGenMode, error) {
+	var attrs []syscall.NetlinkRouteAttr
+	err := i.ps.inNetns(func() error {
+		var err error
+		attrs, err = i.linkAttrs()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return parseAddrGenMode(attrs)
+}
+
+// parseAddrGenMode will find the IFLA_INET6_ADDR_GEN_MODE in the
+// IFLA_AF_SPEC of an RTM_NEWLINK message.
+func parseAddrGenMode(attrs []syscall.NetlinkRouteAttr) (AddrGenMode, error) {
+	for _, attr := range attrs {
+		if attr.Attr.Type != unix.IFLA_AF_SPEC {
+			continue
+		}
+		families, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return 0, err
+		}
+		for _, family := range families {
+			if family.Attr.Type != unix.AF_INET6 {
+				continue
+			}
+			inet6, err := nl.ParseRouteAttr(family.Value)
+			if err != nil {
+				return 0, err
+			}
+			for _, a := range inet6 {
+				if a.Attr.Type == unix.IFLA_INET6_ADDR_GEN_MODE && len(a.Value) >= 1 {
+					return AddrGenMode(a.Value[0]), nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("tap: interface has no IPv6 address generation mode")
+}
+
+// SetStableSecret will set the 128 bit secret used to generate link-local
+// addresses when the AddrGenMode is AddrGenModeStablePrivacy. The secret
+// must be provided as an IPv6 address, as it is by the kernel.
+func (i Interface) SetStableSecret(secret net.IP) error {
+	if len(secret) != net.IPv6len || secret.To4() != nil {
+		return fmt.Errorf("tap: stable secret must be a 128 bit IPv6 address")
+	}
+	return i.writeSysctl("ipv6", "stable_secret", secret.String())
+}
+
+// StableSecret will return the 128 bit secret used to generate link-local
+// addresses when the AddrGenMode is AddrGenModeStablePrivacy. This will
+// return an error if no secret has been set.
+func (i Interface) StableSecret() (net.IP, error) {
+	v, err := i.readSysctl("ipv6", "stable_secret")
+	if err != nil {
+		return nil, err
+	}
+	secret := net.ParseIP(v)
+	if secret == nil {
+		return nil, fmt.Errorf("tap: invalid stable secret: %q", v)
+	}
+	return secret, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseAddrGenMode(t *testing.T) {
+	// linkMsg will build the attributes of an RTM_NEWLINK message with the
+	// provided IFLA_AF_SPEC families.
+	linkMsg := func(families ...*nl.RtAttr) []syscall.NetlinkRouteAttr {
+		afSpec := nl.NewRtAttr(unix.IFLA_AF_SPEC, nil)
+		for _, family := range families {
+			afSpec.AddChild(family)
+		}
+		var buf []byte
+		buf = append(buf, nl.NewRtAttr(unix.IFLA_MTU, nl.Uint32Attr(1500)).Serialize()...)
+		buf = append(buf, afSpec.Serialize()...)
+		attrs, err := nl.ParseRouteAttr(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attrs
+	}
+	inet6 := func(mode AddrGenMode) *nl.RtAttr {
+		attr := nl.NewRtAttr(unix.AF_INET6, nil)
+		attr.AddRtAttr(unix.IFLA_INET6_FLAGS, nl.Uint32Attr(0))
+		attr.AddRtAttr(unix.IFLA_INET6_ADDR_GEN_MODE, nl.Uint8Attr(uint8(mode)))
+		return attr
+	}
+	inet := nl.NewRtAttr(unix.AF_INET, nil)
+
+	for _, tc := range []struct {
+		name  string
+		attrs []syscall.NetlinkRouteAttr
+		want  AddrGenMode
+		err   bool
+	}{
+		{"none", linkMsg(inet, inet6(AddrGenModeNone)), AddrGenModeNone, false},
+		{"random", linkMsg(inet6(AddrGenModeRandom)), AddrGenModeRandom, false},
+		{"no ipv6", linkMsg(inet), 0, true},
+		{"no af spec", nil, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseAddrGenMode(tc.attrs)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddrGenModeInNetns(t *testing.T) {
+	iface := newTestInterfaceInNetns(t, Options{})
+
+	for _, mode := range []AddrGenMode{AddrGenModeNone, AddrGenModeRandom, AddrGenModeEUI64} {
+		if err := iface.SetAddrGenMode(mode); err != nil {
+			t.Fatal(err)
+		}
+		got, err := iface.AddrGenMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != mode {
+			t.Fatalf("AddrGenMode() = %s after setting %s", got, mode)
+		}
+	}
+}
+
+// vim: foldmethod=marker
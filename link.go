@@ -0,0 +1,159 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"fmt"
+	"net"
+)
+
+// OperState is the RFC 2863 operational state of an Interface.
+type OperState uint8
+
+const (
+	// OperStateUnknown is an Interface whose state can't be determined.
+	OperStateUnknown OperState = iota
+
+	// OperStateNotPresent is an Interface missing some component.
+	OperStateNotPresent
+
+	// OperStateDown is an Interface that is down.
+	OperStateDown
+
+	// OperStateLowerLayerDown is an Interface that is down due to the
+	// state of a lower layer.
+	OperStateLowerLayerDown
+
+	// OperStateTesting is an Interface in some test mode.
+	OperStateTesting
+
+	// OperStateDormant is an Interface that is not up, but is waiting on
+	// an external event.
+	OperStateDormant
+
+	// OperStateUp is an Interface that is up, and ready to pass traffic.
+	OperStateUp
+)
+
+// String will return the name of the OperState as used by iproute2.
+func (s OperState) String() string {
+	switch s {
+	case OperStateUnknown:
+		return "unknown"
+	case OperStateNotPresent:
+		return "notpresent"
+	case OperStateDown:
+		return "down"
+	case OperStateLowerLayerDown:
+		return "lowerlayerdown"
+	case OperStateTesting:
+		return "testing"
+	case OperStateDormant:
+		return "dormant"
+	case OperStateUp:
+		return "up"
+	default:
+		return fmt.Sprintf("OperState(%d)", uint8(s))
+	}
+}
+
+// LinkState is the state of an Interface, as reported by the kernel.
+type LinkState struct {
+	// Name is the interface name.
+	Name string
+
+	// Index is the kernel interface index.
+	Index int
+
+	// MTU is the maximum transmission unit.
+	MTU int
+
+	// HardwareAddr is the MAC address of the Interface.
+	HardwareAddr net.HardwareAddr
+
+	// Up is true if the Interface is administratively up.
+	Up bool
+
+	// OperState is the operational state of the Interface.
+	OperState OperState
+
+	// Carrier is true if the Interface has a carrier; for a TAP, this is
+	// true when the TAP is open.
+	Carrier bool
+}
+
+// Index will return the kernel interface index of the Interface.
+func (i Interface) Index() int {
+	return i.ps.index()
+}
+
+// NetInterface will return the current state of the Interface as a
+// *net.Interface.
+func (i Interface) NetInterface() (*net.Interface, error) {
+	return net.InterfaceByIndex(i.ps.index())
+}
+
+// Flags will return the current interface flags of the Interface.
+func (i Interface) Flags() (net.Flags, error) {
+	netif, err := i.NetInterface()
+	if err != nil {
+		return 0, err
+	}
+	return netif.Flags, nil
+}
+
+// MTU will return the current MTU of the Interface.
+func (i Interface) MTU() (int, error) {
+	state, err := i.State()
+	if err != nil {
+		return 0, err
+	}
+	return state.MTU, nil
+}
+
+// HardwareAddr will return the current hardware address of the Interface.
+func (i Interface) HardwareAddr() (net.HardwareAddr, error) {
+	state, err := i.State()
+	if err != nil {
+		return nil, err
+	}
+	return state.HardwareAddr, nil
+}
+
+// IsUp will return true if the Interface is administratively up.
+func (i Interface) IsUp() (bool, error) {
+	state, err := i.State()
+	if err != nil {
+		return false, err
+	}
+	return state.Up, nil
+}
+
+// OperState will return the current operational state of the Interface.
+func (i Interface) OperState() (OperState, error) {
+	state, err := i.State()
+	if err != nil {
+		return OperStateUnknown, err
+	}
+	return state.OperState, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,216 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// LinkEventType is the type of change made to the Interface.
+type LinkEventType uint8
+
+const (
+	// LinkEventAdminState is sent when the Interface is set up or down.
+	LinkEventAdminState LinkEventType = iota + 1
+
+	// LinkEventOperState is sent when the operational state changes.
+	LinkEventOperState
+
+	// LinkEventCarrier is sent when the carrier is gained or lost.
+	LinkEventCarrier
+
+	// LinkEventMTU is sent when the MTU changes.
+	LinkEventMTU
+
+	// LinkEventHardwareAddr is sent when the hardware address changes.
+	LinkEventHardwareAddr
+
+	// LinkEventRename is sent when the Interface is renamed.
+	LinkEventRename
+
+	// LinkEventDelete is sent when the Interface is deleted. No more
+	// events will be sent after this one.
+	LinkEventDelete
+)
+
+// String will return a human readable name of the LinkEventType.
+func (t LinkEventType) String() string {
+	switch t {
+	case LinkEventAdminState:
+		return "admin-state"
+	case LinkEventOperState:
+		return "oper-state"
+	case LinkEventCarrier:
+		return "carrier"
+	case LinkEventMTU:
+		return "mtu"
+	case LinkEventHardwareAddr:
+		return "hardware-addr"
+	case LinkEventRename:
+		return "rename"
+	case LinkEventDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("LinkEventType(%d)", uint8(t))
+	}
+}
+
+// LinkEvent is a change to the Interface.
+type LinkEvent struct {
+	// Type is the type of change.
+	Type LinkEventType
+
+	// State is the state of the Interface after the change, or the last
+	// known state if the Interface was deleted.
+	State LinkState
+}
+
+// newLinkState will convert the netlink.Link into a LinkState.
+func newLinkState(link netlink.Link) LinkState {
+	attrs := link.Attrs()
+	return LinkState{
+		Name:         attrs.Name,
+		Index:        attrs.Index,
+		MTU:          attrs.MTU,
+		HardwareAddr: attrs.HardwareAddr,
+		Up:           attrs.Flags&net.FlagUp != 0,
+		OperState:    OperState(attrs.OperState),
+		Carrier:      attrs.RawFlags&unix.IFF_LOWER_UP != 0,
+	}
+}
+
+// diffLinkState will return the type of every change between the two
+// LinkStates.
+func diffLinkState(prev, next LinkState) []LinkEventType {
+	var ret []LinkEventType
+	if prev.Up != next.Up {
+		ret = append(ret, LinkEventAdminState)
+	}
+	if prev.OperState != next.OperState {
+		ret = append(ret, LinkEventOperState)
+	}
+	if prev.Carrier != next.Carrier {
+		ret = append(ret, LinkEventCarrier)
+	}
+	if prev.MTU != next.MTU {
+		ret = append(ret, LinkEventMTU)
+	}
+	if !bytes.Equal(prev.HardwareAddr, next.HardwareAddr) {
+		ret = append(ret, LinkEventHardwareAddr)
+	}
+	if prev.Name != next.Name {
+		ret = append(ret, LinkEventRename)
+	}
+	return ret
+}
+
+// WatchLink will return a channel of changes made to the Interface, by this
+// process or any other. The channel will be closed after the Interface is
+// deleted (including by closing the Interface), when the provided context is
+// cancelled, or if the subscription fails. Callers must either read from
+// the channel until it is closed, or cancel the context.
+func (i Interface) WatchLink(ctx context.Context) (<-chan LinkEvent, error) {
+	index := i.ps.index()
+
+	// We'll take the initial state after subscribing, so that any change
+	// made in between shows up as an update, rather than being lost.
+	updates, stop, err := subscribe(netlink.LinkSubscribe)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	state := newLinkState(link)
+	events := make(chan LinkEvent)
+
+	go func() {
+		defer func() {
+			close(events)
+			stop()
+		}()
+
+		send := func(ev LinkEvent) bool {
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// We don't stop when the Interface is closed, since closing the
+		// TAP will delete the link, and we'd like to tell the caller about
+		// that before closing the channel.
+		for {
+			var update netlink.LinkUpdate
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+			case update, ok = <-updates:
+				if !ok {
+					return
+				}
+			}
+
+			if int(update.Index) != index {
+				continue
+			}
+
+			// Bridge port changes (including leaving the bridge, which is
+			// an RTM_DELLINK) are sent as AF_BRIDGE messages for the port,
+			// which don't tell us anything about the link itself.
+			if update.IfInfomsg.Family == unix.AF_BRIDGE {
+				continue
+			}
+
+			if update.Header.Type == unix.RTM_DELLINK {
+				send(LinkEvent{Type: LinkEventDelete, State: state})
+				return
+			}
+
+			next := newLinkState(update.Link)
+			for _, typ := range diffLinkState(state, next) {
+				if !send(LinkEvent{Type: typ, State: next}) {
+					return
+				}
+			}
+			state = next
+		}
+	}()
+
+	return events, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,97 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffLinkState(t *testing.T) {
+	base := LinkState{
+		Name:         "tap0",
+		Index:        4,
+		MTU:          1500,
+		HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		OperState:    OperStateDown,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*LinkState)
+		want   []LinkEventType
+	}{
+		{"nothing", func(s *LinkState) {}, nil},
+		{"up", func(s *LinkState) { s.Up = true }, []LinkEventType{LinkEventAdminState}},
+		{"oper", func(s *LinkState) { s.OperState = OperStateUp }, []LinkEventType{LinkEventOperState}},
+		{"carrier", func(s *LinkState) { s.Carrier = true }, []LinkEventType{LinkEventCarrier}},
+		{"mtu", func(s *LinkState) { s.MTU = 9000 }, []LinkEventType{LinkEventMTU}},
+		{"mac", func(s *LinkState) {
+			s.HardwareAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+		}, []LinkEventType{LinkEventHardwareAddr}},
+		{"rename", func(s *LinkState) { s.Name = "tap1" }, []LinkEventType{LinkEventRename}},
+		{"up and running", func(s *LinkState) {
+			s.Up = true
+			s.Carrier = true
+			s.OperState = OperStateUp
+		}, []LinkEventType{LinkEventAdminState, LinkEventOperState, LinkEventCarrier}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next := base
+			next.HardwareAddr = append(net.HardwareAddr{}, base.HardwareAddr...)
+			tc.change(&next)
+			if got := diffLinkState(base, next); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diffLinkState = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWatchLink(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := iface.WatchLink(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iface.SetMTU(1400); err != nil {
+		t.Fatal(err)
+	}
+	for ev := range events {
+		if ev.Type != LinkEventMTU {
+			continue
+		}
+		if ev.State.MTU != 1400 {
+			t.Fatalf("MTU event has MTU %d", ev.State.MTU)
+		}
+		return
+	}
+	t.Fatal("no MTU event before the channel was closed")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,173 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// SetMaster will enslave the Interface to the named master device, such as
+// a bridge or a VRF.
+func (i Interface) SetMaster(name string) error {
+	master, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetMasterByIndex(i.ps.link(), master.Attrs().Index)
+}
+
+// NoMaster will release the Interface from its master device, if any.
+func (i Interface) NoMaster() error {
+	return netlink.LinkSetNoMaster(i.ps.link())
+}
+
+// ensureLink will create the link if a link of the same name does not
+// already exist. If it does exist, it must be of the same type.
+func ensureLink(link netlink.Link) error {
+	name := link.Attrs().Name
+	existing, err := netlink.LinkByName(name)
+	if err == nil {
+		if existing.Type() != link.Type() {
+			return fmt.Errorf("tap: %s exists, but is a %s, not a %s",
+				name, existing.Type(), link.Type())
+		}
+		return nil
+	}
+	var notFound netlink.LinkNotFoundError
+	if !errors.As(err, &notFound) {
+		return err
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return err
+	}
+	return netlink.LinkSetUp(link)
+}
+
+// EnsureBridge will create (and bring up) a Linux bridge with the provided
+// name, unless it already exists.
+func EnsureBridge(name string) error {
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = name
+	return ensureLink(&netlink.Bridge{LinkAttrs: attrs})
+}
+
+// EnsureVRF will create (and bring up) a Linux VRF device with the provided
+// name, bound to the routing table, unless it already exists.
+func EnsureVRF(name string, table uint32) error {
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = name
+	return ensureLink(&netlink.Vrf{LinkAttrs: attrs, Table: table})
+}
+
+// SetBridgeLearning will control if the bridge learns source addresses of
+// frames arriving on the Interface. The Interface must be enslaved to a
+// bridge.
+func (i Interface) SetBridgeLearning(learning bool) error {
+	return netlink.LinkSetLearning(i.ps.link(), learning)
+}
+
+// SetBridgeFlood will control if the bridge floods unknown unicast traffic
+// out of the Interface. The Interface must be enslaved to a bridge.
+func (i Interface) SetBridgeFlood(flood bool) error {
+	return netlink.LinkSetFlood(i.ps.link(), flood)
+}
+
+// SetBridgeHairpin will control if the bridge may send frames back out of
+// the Interface they arrived on. The Interface must be enslaved to a bridge.
+func (i Interface) SetBridgeHairpin(hairpin bool) error {
+	return netlink.LinkSetHairpin(i.ps.link(), hairpin)
+}
+
+// SetBridgeIsolated will control if the Interface is isolated; isolated
+// ports may only send frames to non-isolated ports. The Interface must be
+// enslaved to a bridge.
+func (i Interface) SetBridgeIsolated(isolated bool) error {
+	req := nl.NewNetlinkRequest(unix.RTM_SETLINK, unix.NLM_F_ACK)
+
+	msg := nl.NewIfInfomsg(unix.AF_BRIDGE)
+	msg.Index = int32(i.ps.index())
+	req.AddData(msg)
+
+	var v uint8
+	if isolated {
+		v = 1
+	}
+	br := nl.NewRtAttr(unix.IFLA_PROTINFO|unix.NLA_F_NESTED, nil)
+	br.AddRtAttr(unix.IFLA_BRPORT_ISOLATED, nl.Uint8Attr(v))
+	req.AddData(br)
+
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
+
+// BridgeVLAN is a VLAN allowed on the bridge port of the Interface.
+type BridgeVLAN struct {
+	// ID is the VLAN ID.
+	ID uint16
+
+	// PVID will make this VLAN the port VLAN; untagged frames arriving on
+	// the Interface will be placed in this VLAN.
+	PVID bool
+
+	// Untagged will send frames in this VLAN out of the Interface without
+	// a VLAN tag.
+	Untagged bool
+}
+
+// AddBridgeVLAN will allow the VLAN on the bridge port of the Interface. The
+// Interface must be enslaved to a bridge with VLAN filtering enabled.
+func (i Interface) AddBridgeVLAN(vlan BridgeVLAN) error {
+	return netlink.BridgeVlanAdd(i.ps.link(), vlan.ID, vlan.PVID, vlan.Untagged, false, true)
+}
+
+// RemoveBridgeVLAN will remove the VLAN from the bridge port of the
+// Interface.
+func (i Interface) RemoveBridgeVLAN(id uint16) error {
+	return netlink.BridgeVlanDel(i.ps.link(), id, false, false, false, true)
+}
+
+// BridgeVLANs will return the VLANs allowed on the bridge port of the
+// Interface.
+func (i Interface) BridgeVLANs() ([]BridgeVLAN, error) {
+	vlans, err := netlink.BridgeVlanList()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []BridgeVLAN
+	for _, vlan := range vlans[int32(i.ps.index())] {
+		ret = append(ret, BridgeVLAN{
+			ID:       vlan.Vid,
+			PVID:     vlan.PortVID(),
+			Untagged: vlan.EngressUntag(),
+		})
+	}
+	return ret, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,245 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ReadLatencyBuckets are the upper bounds of the buckets of the read
+	// latency histogram. This is read once when an Interface is created,
+	// so changes only apply to Interfaces created after the change.
+	ReadLatencyBuckets = []time.Duration{
+		time.Microsecond,
+		10 * time.Microsecond,
+		100 * time.Microsecond,
+		time.Millisecond,
+		10 * time.Millisecond,
+		100 * time.Millisecond,
+	}
+)
+
+// ioMetrics are the counters of I/O done by an Interface. This is shared
+// between all copies of the Interface.
+type ioMetrics struct {
+	framesRead      atomic.Uint64
+	bytesRead       atomic.Uint64
+	framesWritten   atomic.Uint64
+	bytesWritten    atomic.Uint64
+	unmarshalErrors atomic.Uint64
+	shortWrites     atomic.Uint64
+
+	readLatencyCount   atomic.Uint64
+	readLatencySum     atomic.Int64
+	readLatencyBuckets []time.Duration
+	readLatency        []atomic.Uint64
+}
+
+func newIOMetrics() *ioMetrics {
+	buckets := append([]time.Duration{}, ReadLatencyBuckets...)
+	return &ioMetrics{
+		readLatencyBuckets: buckets,
+		readLatency:        make([]atomic.Uint64, len(buckets)),
+	}
+}
+
+func (m *ioMetrics) observeRead(latency time.Duration) {
+	m.readLatencyCount.Add(1)
+	m.readLatencySum.Add(int64(latency))
+	for j, bound := range m.readLatencyBuckets {
+		if latency <= bound {
+			m.readLatency[j].Add(1)
+		}
+	}
+}
+
+// Histogram is a snapshot of a cumulative histogram, as used by Prometheus.
+type Histogram struct {
+	// Buckets are the upper bounds of each bucket.
+	Buckets []time.Duration
+
+	// Counts are the number of observations less than or equal to the
+	// upper bound of the bucket at the same index.
+	Counts []uint64
+
+	// Count is the total number of observations.
+	Count uint64
+
+	// Sum is the sum of every observation.
+	Sum time.Duration
+}
+
+// MetricsSnapshot are the I/O counters of an Interface at a point in time.
+type MetricsSnapshot struct {
+	// Interface is the name of the Interface.
+	Interface string
+
+	FramesRead    uint64
+	BytesRead     uint64
+	FramesWritten uint64
+	BytesWritten  uint64
+
+	// UnmarshalErrors is the number of frames read from the TAP that could
+	// not be parsed as an Ethernet Frame.
+	UnmarshalErrors uint64
+
+	// ShortWrites is the number of frames that were only partially written
+	// to the TAP.
+	ShortWrites uint64
+
+	// ReadLatency is the time Read spent processing each Frame after it
+	// was read from the TAP, not including time spent waiting for the
+	// Frame to arrive.
+	ReadLatency Histogram
+}
+
+// MetricsSource is anything that can provide a MetricsSnapshot, such as an
+// Interface.
+type MetricsSource interface {
+	MetricsSnapshot() MetricsSnapshot
+}
+
+// MetricsSnapshot will return the current I/O counters of the Interface.
+func (i Interface) MetricsSnapshot() MetricsSnapshot {
+	m := i.metrics
+	snap := MetricsSnapshot{
+		Interface:       i.Name(),
+		FramesRead:      m.framesRead.Load(),
+		BytesRead:       m.bytesRead.Load(),
+		FramesWritten:   m.framesWritten.Load(),
+		BytesWritten:    m.bytesWritten.Load(),
+		UnmarshalErrors: m.unmarshalErrors.Load(),
+		ShortWrites:     m.shortWrites.Load(),
+		ReadLatency: Histogram{
+			Buckets: append([]time.Duration{}, m.readLatencyBuckets...),
+			Counts:  make([]uint64, len(m.readLatency)),
+			Count:   m.readLatencyCount.Load(),
+			Sum:     time.Duration(m.readLatencySum.Load()),
+		},
+	}
+	for j := range m.readLatency {
+		snap.ReadLatency.Counts[j] = m.readLatency[j].Load()
+	}
+	return snap
+}
+
+// ExpvarMetrics will return an expvar.Var that publishes the MetricsSnapshot
+// of each source, keyed by interface name. This is usually used like:
+//
+//	expvar.Publish("tap", tap.ExpvarMetrics(iface))
+func ExpvarMetrics(sources ...MetricsSource) expvar.Var {
+	return expvar.Func(func() any {
+		ret := map[string]MetricsSnapshot{}
+		for _, source := range sources {
+			snap := source.MetricsSnapshot()
+			ret[snap.Interface] = snap
+		}
+		return ret
+	})
+}
+
+// WritePrometheus will write the MetricsSnapshot of each source to the
+// io.Writer in the Prometheus text exposition format, labelled by
+// interface name.
+func WritePrometheus(w io.Writer, sources ...MetricsSource) error {
+	snaps := make([]MetricsSnapshot, len(sources))
+	for j, source := range sources {
+		snaps[j] = source.MetricsSnapshot()
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(MetricsSnapshot) uint64
+	}{
+		{"tap_frames_read_total", "Frames read from the TAP.",
+			func(s MetricsSnapshot) uint64 { return s.FramesRead }},
+		{"tap_bytes_read_total", "Bytes read from the TAP.",
+			func(s MetricsSnapshot) uint64 { return s.BytesRead }},
+		{"tap_frames_written_total", "Frames written to the TAP.",
+			func(s MetricsSnapshot) uint64 { return s.FramesWritten }},
+		{"tap_bytes_written_total", "Bytes written to the TAP.",
+			func(s MetricsSnapshot) uint64 { return s.BytesWritten }},
+		{"tap_unmarshal_errors_total", "Frames read from the TAP that could not be parsed.",
+			func(s MetricsSnapshot) uint64 { return s.UnmarshalErrors }},
+		{"tap_short_writes_total", "Frames only partially written to the TAP.",
+			func(s MetricsSnapshot) uint64 { return s.ShortWrites }},
+	}
+
+	for _, counter := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n",
+			counter.name, counter.help, counter.name); err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			if _, err := fmt.Fprintf(w, "%s{interface=%s} %d\n",
+				counter.name, promLabel(snap.Interface), counter.value(snap)); err != nil {
+				return err
+			}
+		}
+	}
+
+	const name = "tap_read_duration_seconds"
+	if _, err := fmt.Fprintf(w, "# HELP %s Time spent processing each frame read from the TAP.\n# TYPE %s histogram\n",
+		name, name); err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		label := promLabel(snap.Interface)
+		h := snap.ReadLatency
+		for j, bound := range h.Buckets {
+			if j >= len(h.Counts) {
+				// A MetricsSource outside this package may hand us a
+				// Histogram with fewer Counts than Buckets.
+				break
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket{interface=%s,le=\"%g\"} %d\n",
+				name, label, bound.Seconds(), h.Counts[j]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{interface=%s,le=\"+Inf\"} %d\n%s_sum{interface=%s} %g\n%s_count{interface=%s} %d\n",
+			name, label, h.Count,
+			name, label, h.Sum.Seconds(),
+			name, label, h.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promLabelEscaper escapes the characters the Prometheus text format
+// requires to be escaped in label values, and nothing else.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabel will return the label value quoted and escaped for the
+// Prometheus text format.
+func promLabel(value string) string {
+	return `"` + promLabelEscaper.Replace(value) + `"`
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,122 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromLabel(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{"tap0", `"tap0"`},
+		{"", `""`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{"a\nb", `"a\nb"`},
+		// Anything else is passed through as-is, not Go escaped.
+		{"tap\t0", "\"tap\t0\""},
+		{"täp", `"täp"`},
+		{"\x01", "\"\x01\""},
+	} {
+		if got := promLabel(tc.value); got != tc.want {
+			t.Errorf("promLabel(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+type staticMetrics MetricsSnapshot
+
+func (s staticMetrics) MetricsSnapshot() MetricsSnapshot {
+	return MetricsSnapshot(s)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	err := WritePrometheus(&buf, staticMetrics{
+		Interface:  `tap"0`,
+		FramesRead: 3,
+		ReadLatency: Histogram{
+			Buckets: []time.Duration{time.Microsecond, time.Millisecond},
+			// Fewer Counts than Buckets must not panic.
+			Counts: []uint64{2},
+			Count:  3,
+			Sum:    1500 * time.Microsecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, line := range []string{
+		`tap_frames_read_total{interface="tap\"0"} 3`,
+		`tap_short_writes_total{interface="tap\"0"} 0`,
+		`tap_read_duration_seconds_bucket{interface="tap\"0",le="1e-06"} 2`,
+		`tap_read_duration_seconds_bucket{interface="tap\"0",le="+Inf"} 3`,
+		`tap_read_duration_seconds_sum{interface="tap\"0"} 0.0015`,
+		`tap_read_duration_seconds_count{interface="tap\"0"} 3`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, `le="0.001"`) {
+		t.Errorf("bucket without a count was written:\n%s", out)
+	}
+}
+
+func TestObserveRead(t *testing.T) {
+	saved := ReadLatencyBuckets
+	defer func() { ReadLatencyBuckets = saved }()
+	ReadLatencyBuckets = []time.Duration{time.Microsecond, time.Millisecond}
+
+	m := newIOMetrics()
+	// Changes after the Interface is created must not affect it.
+	ReadLatencyBuckets = []time.Duration{time.Second}
+
+	for _, latency := range []time.Duration{
+		time.Nanosecond,
+		time.Microsecond,
+		time.Millisecond - 1,
+		time.Second,
+	} {
+		m.observeRead(latency)
+	}
+
+	want := []uint64{2, 3}
+	for j := range want {
+		if got := m.readLatency[j].Load(); got != want[j] {
+			t.Errorf("bucket %s has %d observations, want %d",
+				m.readLatencyBuckets[j], got, want[j])
+		}
+	}
+	if got := m.readLatencyCount.Load(); got != 4 {
+		t.Errorf("count is %d, want 4", got)
+	}
+}
+
+// vim: foldmethod=marker
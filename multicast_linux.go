@@ -0,0 +1,145 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/vishvananda/netlink"
+)
+
+// SetPromisc will enable or disable promiscuous mode on the Interface.
+func (i Interface) SetPromisc(promisc bool) error {
+	iface := i.ps.link()
+	if promisc {
+		return netlink.SetPromiscOn(iface)
+	}
+	return netlink.SetPromiscOff(iface)
+}
+
+// SetAllMulticast will enable or disable receiving all multicast traffic on
+// the Interface.
+func (i Interface) SetAllMulticast(allmulti bool) error {
+	iface := i.ps.link()
+	if allmulti {
+		return netlink.LinkSetAllmulticastOn(iface)
+	}
+	return netlink.LinkSetAllmulticastOff(iface)
+}
+
+// ifReqHwaddr is an ABI compatible version of ifreq as seen in
+// `netdevice(7)`, with the ifr_hwaddr member of the union.
+type ifReqHwaddr struct {
+	Name   [syscall.IFNAMSIZ]byte
+	Family uint16
+	Data   [14]byte
+
+	_ [40 - syscall.IFNAMSIZ - 16]uint8
+}
+
+func (i Interface) multicastIoctl(action uintptr, mac net.HardwareAddr) error {
+	if len(mac) != 6 || mac[0]&0x01 == 0 {
+		return fmt.Errorf("tap: %s is not a multicast hardware address", mac)
+	}
+
+	req := ifReqHwaddr{
+		Name:   i.ifname(),
+		Family: syscall.AF_UNSPEC,
+	}
+	copy(req.Data[:], mac)
+
+	// The ioctl finds the interface by name in the namespace of the socket,
+	// so the socket has to be created in the Interface's namespace.
+	return i.ps.inNetns(func() error {
+		file, err := afInet()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return ioctl(action, file.Fd(), uintptr(unsafe.Pointer(&req)))
+	})
+}
+
+// AddMulticast will join the link-layer multicast group on the Interface.
+func (i Interface) AddMulticast(mac net.HardwareAddr) error {
+	return i.multicastIoctl(syscall.SIOCADDMULTI, mac)
+}
+
+// RemoveMulticast will leave the link-layer multicast group on the Interface.
+func (i Interface) RemoveMulticast(mac net.HardwareAddr) error {
+	return i.multicastIoctl(syscall.SIOCDELMULTI, mac)
+}
+
+// Multicasts will return the link-layer multicast groups joined on the
+// Interface, including those joined by the kernel.
+func (i Interface) Multicasts() ([]net.HardwareAddr, error) {
+	var ret []net.HardwareAddr
+	// /proc/net is the namespace of the process, so we need the namespace
+	// of this thread, which inNetns has switched to the Interface's.
+	err := i.ps.inNetns(func() error {
+		file, err := os.Open("/proc/thread-self/net/dev_mcast")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		ret, err = parseDevMcast(file, i.ps.index())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parseDevMcast will return the addresses for the interface index from the
+// contents of /proc/net/dev_mcast.
+func parseDevMcast(r io.Reader, index int) ([]net.HardwareAddr, error) {
+	// Each line is the ifindex, name, refcount, global use count, and the
+	// address in hex.
+	idx := strconv.Itoa(index)
+	var ret []net.HardwareAddr
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 5 || fields[0] != idx {
+			continue
+		}
+		mac, err := hex.DecodeString(fields[4])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, net.HardwareAddr(mac))
+	}
+	return ret, scanner.Err()
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,84 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDevMcast(t *testing.T) {
+	const devMcast = `1    lo              1     0     01005e000001
+4    tap0            1     0     333300000001
+4    tap0            2     0     01005e000001
+14   tap10           1     0     333300000002
+4    tap0            1     0     not-hex-but-too-many fields
+`
+	for _, tc := range []struct {
+		index int
+		want  []net.HardwareAddr
+	}{
+		{4, []net.HardwareAddr{
+			{0x33, 0x33, 0, 0, 0, 0x01},
+			{0x01, 0x00, 0x5e, 0, 0, 0x01},
+		}},
+		{14, []net.HardwareAddr{{0x33, 0x33, 0, 0, 0, 0x02}}},
+		{2, nil},
+	} {
+		got, err := parseDevMcast(strings.NewReader(devMcast), tc.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("index %d: got %v, want %v", tc.index, got, tc.want)
+		}
+	}
+
+	if _, err := parseDevMcast(strings.NewReader("4 tap0 1 0 zz\n"), 4); err == nil {
+		t.Error("expected an error for a bad address")
+	}
+}
+
+func TestMulticastsInNetns(t *testing.T) {
+	iface := newTestInterfaceInNetns(t, Options{})
+
+	group := net.HardwareAddr{0x01, 0x00, 0x5e, 0x7f, 0x00, 0x42}
+	if err := iface.AddMulticast(group); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := iface.Multicasts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if g.String() == group.String() {
+			return
+		}
+	}
+	t.Fatalf("%s isn't in %v", group, groups)
+}
+
+// vim: foldmethod=marker
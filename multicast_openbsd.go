@@ -0,0 +1,73 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build openbsd
+
+package tap
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// ifreqMulti is the ifreq used to join or leave a link-layer multicast
+// group.
+type ifreqMulti struct {
+	Name   [syscall.IFNAMSIZ]byte
+	Len    uint8
+	Family uint8
+	Data   [14]byte
+}
+
+func (i Interface) multicastIoctl(action uintptr, mac net.HardwareAddr) error {
+	if len(mac) != 6 || mac[0]&0x01 == 0 {
+		return fmt.Errorf("tap: %s is not a multicast hardware address", mac)
+	}
+
+	file, err := afInet()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// ether_addmulti(9) takes an AF_UNSPEC sockaddr with the MAC address
+	// in the payload.
+	req := ifreqMulti{
+		Name:   i.ifname(),
+		Len:    16,
+		Family: syscall.AF_UNSPEC,
+	}
+	copy(req.Data[:], mac)
+	return ioctl(action, file.Fd(), uintptr(unsafe.Pointer(&req)))
+}
+
+// AddMulticast will join the link-layer multicast group on the Interface.
+func (i Interface) AddMulticast(mac net.HardwareAddr) error {
+	return i.multicastIoctl(syscall.SIOCADDMULTI, mac)
+}
+
+// RemoveMulticast will leave the link-layer multicast group on the Interface.
+func (i Interface) RemoveMulticast(mac net.HardwareAddr) error {
+	return i.multicastIoctl(syscall.SIOCDELMULTI, mac)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,298 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/mdlayher/ethernet"
+)
+
+const (
+	ipv6HeaderLen    = 40
+	ipProtocolICMPv6 = 58
+
+	// ndpHopLimit is the only hop limit allowed on Neighbor Discovery
+	// messages, from RFC 4861, Section 7.1.
+	ndpHopLimit = 255
+
+	icmpv6TypeNeighborSolicitation  uint8 = 135
+	icmpv6TypeNeighborAdvertisement uint8 = 136
+
+	ndpOptionSourceLinkLayerAddr uint8 = 1
+	ndpOptionTargetLinkLayerAddr uint8 = 2
+
+	ndpFlagRouter    uint8 = 0x80
+	ndpFlagSolicited uint8 = 0x40
+	ndpFlagOverride  uint8 = 0x20
+)
+
+var (
+	// ipv6AllNodes is the link-local all-nodes multicast group.
+	ipv6AllNodes = net.ParseIP("ff02::1")
+)
+
+// ipv6MulticastMAC will return the Ethernet multicast address that the
+// IPv6 multicast address is mapped to, from RFC 2464, Section 7.
+func ipv6MulticastMAC(ip net.IP) net.HardwareAddr {
+	ip = ip.To16()
+	return net.HardwareAddr{0x33, 0x33, ip[12], ip[13], ip[14], ip[15]}
+}
+
+// ipv6SolicitedNodeMulticast will return the solicited-node multicast
+// address for the IPv6 address, from RFC 4291, Section 2.7.1.
+func ipv6SolicitedNodeMulticast(ip net.IP) net.IP {
+	ip = ip.To16()
+	return net.IP{
+		0xff, 0x02, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0x01, 0xff, ip[13], ip[14], ip[15],
+	}
+}
+
+// icmpv6Checksum will compute the ICMPv6 checksum of the message, including
+// the IPv6 pseudo-header. The checksum field of the message must be zero.
+func icmpv6Checksum(src, dst net.IP, msg []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for len(b) >= 2 {
+			sum += uint32(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		if len(b) == 1 {
+			sum += uint32(b[0]) << 8
+		}
+	}
+
+	add(src.To16())
+	add(dst.To16())
+	sum += uint32(len(msg))
+	sum += ipProtocolICMPv6
+	add(msg)
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// parseICMPv6 will return the source, destination and ICMPv6 message of
+// the Frame. The checksum of the message will be validated. Messages
+// behind IPv6 extension headers are not supported.
+func parseICMPv6(frame *ethernet.Frame) (net.IP, net.IP, []byte, error) {
+	if frame.EtherType != ethernet.EtherTypeIPv6 {
+		return nil, nil, nil, fmt.Errorf("tap: frame is not IPv6")
+	}
+	b := frame.Payload
+	if len(b) < ipv6HeaderLen {
+		return nil, nil, nil, io.ErrUnexpectedEOF
+	}
+	if b[0]>>4 != 6 {
+		return nil, nil, nil, fmt.Errorf("tap: frame is not IPv6")
+	}
+	if b[6] != ipProtocolICMPv6 {
+		return nil, nil, nil, fmt.Errorf("tap: frame is not ICMPv6")
+	}
+
+	plen := int(binary.BigEndian.Uint16(b[4:6]))
+	if len(b) < ipv6HeaderLen+plen || plen < 4 {
+		return nil, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	var (
+		src = net.IP(append([]byte{}, b[8:24]...))
+		dst = net.IP(append([]byte{}, b[24:40]...))
+		msg = append([]byte{}, b[ipv6HeaderLen:ipv6HeaderLen+plen]...)
+	)
+
+	csum := binary.BigEndian.Uint16(msg[2:4])
+	msg[2], msg[3] = 0, 0
+	if icmpv6Checksum(src, dst, msg) != csum {
+		return nil, nil, nil, fmt.Errorf("tap: invalid ICMPv6 checksum")
+	}
+	binary.BigEndian.PutUint16(msg[2:4], csum)
+
+	// Neighbor Discovery must not have been forwarded by a router; the
+	// hop limit check is done here since every ICMPv6 message this package
+	// handles is Neighbor Discovery.
+	if b[7] != ndpHopLimit {
+		return nil, nil, nil, fmt.Errorf("tap: invalid NDP hop limit")
+	}
+
+	return src, dst, msg, nil
+}
+
+// icmpv6Frame will build an Ethernet Frame containing the ICMPv6 message,
+// computing the checksum.
+func icmpv6Frame(
+	srcMAC, dstMAC net.HardwareAddr,
+	src, dst net.IP,
+	msg []byte,
+) *ethernet.Frame {
+	b := make([]byte, ipv6HeaderLen+len(msg))
+	b[0] = 6 << 4
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(msg)))
+	b[6] = ipProtocolICMPv6
+	b[7] = ndpHopLimit
+	copy(b[8:24], src.To16())
+	copy(b[24:40], dst.To16())
+
+	icmp := b[ipv6HeaderLen:]
+	copy(icmp, msg)
+	icmp[2], icmp[3] = 0, 0
+	binary.BigEndian.PutUint16(icmp[2:4], icmpv6Checksum(src, dst, icmp))
+
+	return &ethernet.Frame{
+		Destination: dstMAC,
+		Source:      srcMAC,
+		EtherType:   ethernet.EtherTypeIPv6,
+		Payload:     b,
+	}
+}
+
+// ndpLinkLayerOption will find the first link-layer address option of the
+// provided type in the NDP options.
+func ndpLinkLayerOption(opts []byte, typ uint8) net.HardwareAddr {
+	for len(opts) >= 2 {
+		l := int(opts[1]) * 8
+		if l == 0 || l > len(opts) {
+			return nil
+		}
+		if opts[0] == typ && l >= 8 {
+			return append(net.HardwareAddr{}, opts[2:8]...)
+		}
+		opts = opts[l:]
+	}
+	return nil
+}
+
+func marshalNDPLinkLayerOption(typ uint8, mac net.HardwareAddr) []byte {
+	return append([]byte{typ, 1}, mac[:6]...)
+}
+
+// ndpNeighborSolicitation is an ICMPv6 Neighbor Solicitation, from
+// RFC 4861, Section 4.3.
+type ndpNeighborSolicitation struct {
+	Target              net.IP
+	SourceLinkLayerAddr net.HardwareAddr
+}
+
+// MarshalBinary will encode the message with a zero checksum.
+func (ns *ndpNeighborSolicitation) MarshalBinary() ([]byte, error) {
+	if ns.Target.To16() == nil || ns.Target.To4() != nil {
+		return nil, fmt.Errorf("tap: ndp target must be IPv6")
+	}
+	b := make([]byte, 24)
+	b[0] = icmpv6TypeNeighborSolicitation
+	copy(b[8:24], ns.Target.To16())
+	if ns.SourceLinkLayerAddr != nil {
+		b = append(b, marshalNDPLinkLayerOption(
+			ndpOptionSourceLinkLayerAddr,
+			ns.SourceLinkLayerAddr,
+		)...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary will decode the message.
+func (ns *ndpNeighborSolicitation) UnmarshalBinary(b []byte) error {
+	if len(b) < 24 {
+		return io.ErrUnexpectedEOF
+	}
+	if b[0] != icmpv6TypeNeighborSolicitation || b[1] != 0 {
+		return fmt.Errorf("tap: message is not a neighbor solicitation")
+	}
+	ns.Target = append(net.IP{}, b[8:24]...)
+	ns.SourceLinkLayerAddr = ndpLinkLayerOption(b[24:], ndpOptionSourceLinkLayerAddr)
+	return nil
+}
+
+// ndpNeighborAdvertisement is an ICMPv6 Neighbor Advertisement, from
+// RFC 4861, Section 4.4.
+type ndpNeighborAdvertisement struct {
+	Router              bool
+	Solicited           bool
+	Override            bool
+	Target              net.IP
+	TargetLinkLayerAddr net.HardwareAddr
+}
+
+// MarshalBinary will encode the message with a zero checksum.
+func (na *ndpNeighborAdvertisement) MarshalBinary() ([]byte, error) {
+	if na.Target.To16() == nil || na.Target.To4() != nil {
+		return nil, fmt.Errorf("tap: ndp target must be IPv6")
+	}
+	b := make([]byte, 24)
+	b[0] = icmpv6TypeNeighborAdvertisement
+	if na.Router {
+		b[4] |= ndpFlagRouter
+	}
+	if na.Solicited {
+		b[4] |= ndpFlagSolicited
+	}
+	if na.Override {
+		b[4] |= ndpFlagOverride
+	}
+	copy(b[8:24], na.Target.To16())
+	if na.TargetLinkLayerAddr != nil {
+		b = append(b, marshalNDPLinkLayerOption(
+			ndpOptionTargetLinkLayerAddr,
+			na.TargetLinkLayerAddr,
+		)...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary will decode the message.
+func (na *ndpNeighborAdvertisement) UnmarshalBinary(b []byte) error {
+	if len(b) < 24 {
+		return io.ErrUnexpectedEOF
+	}
+	if b[0] != icmpv6TypeNeighborAdvertisement || b[1] != 0 {
+		return fmt.Errorf("tap: message is not a neighbor advertisement")
+	}
+	na.Router = b[4]&ndpFlagRouter != 0
+	na.Solicited = b[4]&ndpFlagSolicited != 0
+	na.Override = b[4]&ndpFlagOverride != 0
+	na.Target = append(net.IP{}, b[8:24]...)
+	na.TargetLinkLayerAddr = ndpLinkLayerOption(b[24:], ndpOptionTargetLinkLayerAddr)
+	return nil
+}
+
+// unsolicitedNA will build an unsolicited Neighbor Advertisement to the
+// all-nodes group announcing that the IP is at the provided hardware address.
+func unsolicitedNA(mac net.HardwareAddr, ip net.IP, router bool) (*ethernet.Frame, error) {
+	na := ndpNeighborAdvertisement{
+		Router:              router,
+		Override:            true,
+		Target:              ip,
+		TargetLinkLayerAddr: mac,
+	}
+	msg, err := na.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return icmpv6Frame(mac, ipv6MulticastMAC(ipv6AllNodes), ip, ipv6AllNodes, msg), nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,216 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// NDPResponderOptions contains configuration for an NDPResponder.
+type NDPResponderOptions struct {
+	// Router will set the router flag on every Neighbor Advertisement.
+	Router bool
+
+	// NoOverride will clear the override flag on every Neighbor
+	// Advertisement, which should be set when answering for anycast or
+	// proxied addresses, so that peers will prefer another advertisement
+	// if one exists.
+	NoOverride bool
+
+	// Unsolicited will send an unsolicited Neighbor Advertisement whenever
+	// an entry is added to the table, or an existing entry's hardware
+	// address is changed.
+	Unsolicited bool
+}
+
+// NDPResponder will answer ICMPv6 Neighbor Solicitations arriving on a TAP
+// for a table of IPv6 addresses owned by userspace, including Duplicate
+// Address Detection probes. The table may be changed at any time.
+//
+// NDPResponder is itself a FrameReadWriter. Calls to Read will answer any
+// Neighbor Solicitations for addresses in the table, and return every other
+// Frame to the caller, which allows it to be stacked with an ARPResponder
+// or any other consumer of Frames on the same TAP.
+type NDPResponder struct {
+	frames FrameReadWriter
+	opts   NDPResponderOptions
+
+	mu    sync.RWMutex
+	table map[[net.IPv6len]byte]net.HardwareAddr
+}
+
+// NewNDPResponder will create a new NDPResponder reading and writing Frames
+// to the provided FrameReadWriter, which is usually an Interface.
+func NewNDPResponder(frames FrameReadWriter, opts NDPResponderOptions) *NDPResponder {
+	return &NDPResponder{
+		frames: frames,
+		opts:   opts,
+		table:  map[[net.IPv6len]byte]net.HardwareAddr{},
+	}
+}
+
+func ndpTableKey(ip net.IP) ([net.IPv6len]byte, error) {
+	var key [net.IPv6len]byte
+	if ip.To16() == nil || ip.To4() != nil {
+		return key, fmt.Errorf("tap: %s is not an IPv6 address", ip)
+	}
+	copy(key[:], ip.To16())
+	return key, nil
+}
+
+// Set will answer Neighbor Solicitations for the IP with the provided
+// hardware address, replacing any existing entry for the IP.
+func (r *NDPResponder) Set(ip net.IP, mac net.HardwareAddr) error {
+	key, err := ndpTableKey(ip)
+	if err != nil {
+		return err
+	}
+	if len(mac) != 6 {
+		return fmt.Errorf("tap: ndp hardware addresses must be 48 bits")
+	}
+
+	r.mu.Lock()
+	old, ok := r.table[key]
+	r.table[key] = append(net.HardwareAddr{}, mac...)
+	r.mu.Unlock()
+
+	if !r.opts.Unsolicited || (ok && bytes.Equal(old, mac)) {
+		return nil
+	}
+
+	frame, err := unsolicitedNA(mac, ip, r.opts.Router)
+	if err != nil {
+		return err
+	}
+	return r.frames.Write(frame)
+}
+
+// Remove will stop answering Neighbor Solicitations for the IP.
+func (r *NDPResponder) Remove(ip net.IP) {
+	key, err := ndpTableKey(ip)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.table, key)
+}
+
+// Lookup will return the hardware address Neighbor Solicitations for the IP
+// are being answered with, if any.
+func (r *NDPResponder) Lookup(ip net.IP) (net.HardwareAddr, bool) {
+	key, err := ndpTableKey(ip)
+	if err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mac, ok := r.table[key]
+	return mac, ok
+}
+
+// Read will read Frames from the underlying FrameReadWriter, answering any
+// Neighbor Solicitations for addresses in the table, and returning the next
+// Frame that was not handled.
+func (r *NDPResponder) Read() (*ethernet.Frame, error) {
+	for {
+		frame, err := r.frames.Read()
+		if err != nil {
+			return nil, err
+		}
+		handled, err := r.handle(frame)
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			return frame, nil
+		}
+	}
+}
+
+// Write will write the Frame to the underlying FrameReadWriter.
+func (r *NDPResponder) Write(frame *ethernet.Frame) error {
+	return r.frames.Write(frame)
+}
+
+// handle will reply to the Frame if it's a Neighbor Solicitation for an
+// address in the table, returning true if the Frame was consumed.
+func (r *NDPResponder) handle(frame *ethernet.Frame) (bool, error) {
+	if frame.EtherType != ethernet.EtherTypeIPv6 {
+		return false, nil
+	}
+
+	src, _, msg, err := parseICMPv6(frame)
+	if err != nil {
+		// Not something we understand; let the caller deal with it.
+		return false, nil
+	}
+
+	var ns ndpNeighborSolicitation
+	if err := ns.UnmarshalBinary(msg); err != nil {
+		return false, nil
+	}
+
+	mac, ok := r.Lookup(ns.Target)
+	if !ok {
+		return false, nil
+	}
+
+	na := ndpNeighborAdvertisement{
+		Router:              r.opts.Router,
+		Solicited:           true,
+		Override:            !r.opts.NoOverride,
+		Target:              ns.Target,
+		TargetLinkLayerAddr: mac,
+	}
+
+	var (
+		dst    = src
+		dstMAC = ns.SourceLinkLayerAddr
+	)
+
+	if src.IsUnspecified() {
+		// This is a Duplicate Address Detection probe; the sender doesn't
+		// have an address yet, so we defend ours by telling everyone,
+		// per RFC 4861, Section 7.2.4.
+		na.Solicited = false
+		dst = ipv6AllNodes
+		dstMAC = ipv6MulticastMAC(ipv6AllNodes)
+	} else if dstMAC == nil {
+		dstMAC = frame.Source
+	}
+
+	reply, err := na.MarshalBinary()
+	if err != nil {
+		return true, err
+	}
+	replyFrame := icmpv6Frame(mac, dstMAC, ns.Target, dst, reply)
+	replyFrame.ServiceVLAN = frame.ServiceVLAN
+	replyFrame.VLAN = frame.VLAN
+	return true, r.frames.Write(replyFrame)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,328 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/ethernet"
+)
+
+func TestIPv6Multicast(t *testing.T) {
+	for _, tc := range []struct {
+		ip            string
+		solicitedNode string
+		mac           string
+	}{
+		{"fe80::1", "ff02::1:ff00:1", "33:33:ff:00:00:01"},
+		{"2001:db8::abcd:1234", "ff02::1:ffcd:1234", "33:33:ff:cd:12:34"},
+	} {
+		ip := net.ParseIP(tc.ip)
+		sn := ipv6SolicitedNodeMulticast(ip)
+		if !sn.Equal(net.ParseIP(tc.solicitedNode)) {
+			t.Errorf("solicited-node of %s is %s, want %s", tc.ip, sn, tc.solicitedNode)
+		}
+		if mac := ipv6MulticastMAC(sn).String(); mac != tc.mac {
+			t.Errorf("multicast MAC of %s is %s, want %s", sn, mac, tc.mac)
+		}
+	}
+	if mac := ipv6MulticastMAC(ipv6AllNodes).String(); mac != "33:33:00:00:00:01" {
+		t.Errorf("multicast MAC of all-nodes is %s", mac)
+	}
+}
+
+func TestICMPv6Checksum(t *testing.T) {
+	ns := ndpNeighborSolicitation{
+		Target:              net.ParseIP("fe80::1"),
+		SourceLinkLayerAddr: testPeerMAC,
+	}
+	msg, err := ns.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Computed independently of this package.
+	const want = 0x7a97
+	sum := icmpv6Checksum(net.ParseIP("fe80::2"), net.ParseIP("ff02::1:ff00:1"), msg)
+	if sum != want {
+		t.Fatalf("checksum is %#04x, want %#04x", sum, want)
+	}
+}
+
+func TestNDPMessages(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		msg  interface {
+			MarshalBinary() ([]byte, error)
+			UnmarshalBinary([]byte) error
+		}
+		empty func() interface{ UnmarshalBinary([]byte) error }
+	}{
+		{
+			name: "solicitation",
+			msg: &ndpNeighborSolicitation{
+				Target:              net.ParseIP("2001:db8::1"),
+				SourceLinkLayerAddr: testPeerMAC,
+			},
+			empty: func() interface{ UnmarshalBinary([]byte) error } {
+				return &ndpNeighborSolicitation{}
+			},
+		},
+		{
+			name: "solicitation without an option",
+			msg:  &ndpNeighborSolicitation{Target: net.ParseIP("2001:db8::1")},
+			empty: func() interface{ UnmarshalBinary([]byte) error } {
+				return &ndpNeighborSolicitation{}
+			},
+		},
+		{
+			name: "advertisement",
+			msg: &ndpNeighborAdvertisement{
+				Router:              true,
+				Override:            true,
+				Target:              net.ParseIP("2001:db8::1"),
+				TargetLinkLayerAddr: testMAC,
+			},
+			empty: func() interface{ UnmarshalBinary([]byte) error } {
+				return &ndpNeighborAdvertisement{}
+			},
+		},
+		{
+			name: "solicited advertisement",
+			msg: &ndpNeighborAdvertisement{
+				Solicited: true,
+				Target:    net.ParseIP("2001:db8::1"),
+			},
+			empty: func() interface{ UnmarshalBinary([]byte) error } {
+				return &ndpNeighborAdvertisement{}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.msg.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tc.empty()
+			if err := got.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.msg) {
+				t.Fatalf("got %+v, want %+v", got, tc.msg)
+			}
+
+			// The other message type must be rejected.
+			var ns ndpNeighborSolicitation
+			var na ndpNeighborAdvertisement
+			if ns.UnmarshalBinary(b) == nil && na.UnmarshalBinary(b) == nil {
+				t.Fatal("message was accepted as both types")
+			}
+		})
+	}
+
+	if _, err := (&ndpNeighborSolicitation{Target: net.IPv4(192, 0, 2, 1)}).MarshalBinary(); err == nil {
+		t.Error("solicitation accepted an IPv4 target")
+	}
+	if err := (&ndpNeighborAdvertisement{}).UnmarshalBinary(make([]byte, 23)); err == nil {
+		t.Error("advertisement accepted a short message")
+	}
+}
+
+func TestNDPLinkLayerOption(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []byte
+		want net.HardwareAddr
+	}{
+		{"none", nil, nil},
+		{"source", []byte{1, 1, 2, 0, 0, 0, 0, 2}, testPeerMAC},
+		{"after another option", []byte{
+			5, 1, 0, 0, 0, 0, 0x05, 0xdc,
+			1, 1, 2, 0, 0, 0, 0, 2,
+		}, testPeerMAC},
+		{"only target", []byte{2, 1, 2, 0, 0, 0, 0, 2}, nil},
+		{"zero length", []byte{5, 0, 1, 1, 2, 0, 0, 0, 0, 2}, nil},
+		{"truncated", []byte{1, 2, 2, 0, 0, 0, 0, 2}, nil},
+	} {
+		got := ndpLinkLayerOption(tc.opts, ndpOptionSourceLinkLayerAddr)
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// nsFrame will build a Neighbor Solicitation for the target from the peer.
+func nsFrame(t *testing.T, src, target net.IP) *ethernet.Frame {
+	t.Helper()
+	ns := ndpNeighborSolicitation{Target: target}
+	if !src.IsUnspecified() {
+		ns.SourceLinkLayerAddr = testPeerMAC
+	}
+	msg, err := ns.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sn := ipv6SolicitedNodeMulticast(target)
+	return icmpv6Frame(testPeerMAC, ipv6MulticastMAC(sn), src, sn, msg)
+}
+
+func TestParseICMPv6(t *testing.T) {
+	var (
+		src    = net.ParseIP("fe80::2")
+		target = net.ParseIP("fe80::1")
+	)
+
+	frame := nsFrame(t, src, target)
+	gotSrc, gotDst, msg, err := parseICMPv6(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotSrc.Equal(src) || !gotDst.Equal(ipv6SolicitedNodeMulticast(target)) {
+		t.Fatalf("parsed %s -> %s", gotSrc, gotDst)
+	}
+	var ns ndpNeighborSolicitation
+	if err := ns.UnmarshalBinary(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		mangle func(*ethernet.Frame)
+	}{
+		{"bad checksum", func(f *ethernet.Frame) { f.Payload[ipv6HeaderLen+2] ^= 0xff }},
+		{"forwarded", func(f *ethernet.Frame) { f.Payload[7] = 64 }},
+		{"not icmpv6", func(f *ethernet.Frame) { f.Payload[6] = 17 }},
+		{"not ipv6", func(f *ethernet.Frame) { f.EtherType = ethernet.EtherTypeIPv4 }},
+		{"truncated", func(f *ethernet.Frame) { f.Payload = f.Payload[:len(f.Payload)-1] }},
+	} {
+		frame := nsFrame(t, src, target)
+		tc.mangle(frame)
+		if _, _, _, err := parseICMPv6(frame); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestNDPResponder(t *testing.T) {
+	var (
+		ours   = net.ParseIP("2001:db8::1")
+		theirs = net.ParseIP("2001:db8::2")
+		other  = net.ParseIP("2001:db8::3")
+	)
+
+	for _, tc := range []struct {
+		name    string
+		frame   *ethernet.Frame
+		opts    NDPResponderOptions
+		handled bool
+		dst     net.IP
+		dstMAC  net.HardwareAddr
+		want    ndpNeighborAdvertisement
+	}{
+		{
+			name:    "solicitation",
+			frame:   nsFrame(t, theirs, ours),
+			handled: true,
+			dst:     theirs,
+			dstMAC:  testPeerMAC,
+			want: ndpNeighborAdvertisement{
+				Solicited:           true,
+				Override:            true,
+				Target:              ours,
+				TargetLinkLayerAddr: testMAC,
+			},
+		},
+		{
+			name:    "router without override",
+			frame:   nsFrame(t, theirs, ours),
+			opts:    NDPResponderOptions{Router: true, NoOverride: true},
+			handled: true,
+			dst:     theirs,
+			dstMAC:  testPeerMAC,
+			want: ndpNeighborAdvertisement{
+				Router:              true,
+				Solicited:           true,
+				Target:              ours,
+				TargetLinkLayerAddr: testMAC,
+			},
+		},
+		{
+			name:    "duplicate address detection",
+			frame:   nsFrame(t, net.IPv6unspecified, ours),
+			handled: true,
+			dst:     ipv6AllNodes,
+			dstMAC:  ipv6MulticastMAC(ipv6AllNodes),
+			want: ndpNeighborAdvertisement{
+				Override:            true,
+				Target:              ours,
+				TargetLinkLayerAddr: testMAC,
+			},
+		},
+		{
+			name:  "someone else",
+			frame: nsFrame(t, theirs, other),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frames := newTestFrames(tc.frame)
+			r := NewNDPResponder(frames, tc.opts)
+			if err := r.Set(ours, testMAC); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.Read()
+			written := frames.Written()
+			if !tc.handled {
+				if got != tc.frame || len(written) != 0 {
+					t.Fatalf("Read() = %v, %v, wrote %d; want the frame passed through",
+						got, err, len(written))
+				}
+				return
+			}
+			if got != nil || len(written) != 1 {
+				t.Fatalf("Read() = %v, %v, wrote %d; want one reply", got, err, len(written))
+			}
+
+			reply := written[0]
+			if !bytes.Equal(reply.Destination, tc.dstMAC) {
+				t.Errorf("reply sent to %s, want %s", reply.Destination, tc.dstMAC)
+			}
+			src, dst, msg, err := parseICMPv6(reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !src.Equal(ours) || !dst.Equal(tc.dst) {
+				t.Errorf("reply is %s -> %s, want %s -> %s", src, dst, ours, tc.dst)
+			}
+			var na ndpNeighborAdvertisement
+			if err := na.UnmarshalBinary(msg); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(na, tc.want) {
+				t.Errorf("reply is %+v, want %+v", na, tc.want)
+			}
+		})
+	}
+}
+
+// vim: foldmethod=marker
@@ -23,41 +23,261 @@
 package tap
 
 import (
+	"fmt"
 	"net"
+	"strings"
 
 	"github.com/vishvananda/netlink"
 )
 
+// NeighborState is the state of an entry in the ARP / NDP table, as tracked
+// by the kernel's Neighbor Unreachability Detection (NUD) state machine.
+type NeighborState uint16
+
+const (
+	// NeighborStateIncomplete is an entry that is currently being resolved.
+	NeighborStateIncomplete NeighborState = netlink.NUD_INCOMPLETE
+
+	// NeighborStateReachable is an entry that has been recently confirmed.
+	NeighborStateReachable NeighborState = netlink.NUD_REACHABLE
+
+	// NeighborStateStale is an entry that is valid, but has not been
+	// confirmed recently.
+	NeighborStateStale NeighborState = netlink.NUD_STALE
+
+	// NeighborStateDelay is a stale entry that is waiting on upper layer
+	// confirmation before being probed.
+	NeighborStateDelay NeighborState = netlink.NUD_DELAY
+
+	// NeighborStateProbe is an entry that is currently being re-confirmed.
+	NeighborStateProbe NeighborState = netlink.NUD_PROBE
+
+	// NeighborStateFailed is an entry that could not be resolved.
+	NeighborStateFailed NeighborState = netlink.NUD_FAILED
+
+	// NeighborStateNoARP is an entry that is valid, and will never be
+	// resolved or re-confirmed.
+	NeighborStateNoARP NeighborState = netlink.NUD_NOARP
+
+	// NeighborStatePermanent is a static entry that will never expire.
+	NeighborStatePermanent NeighborState = netlink.NUD_PERMANENT
+)
+
+var neighborStateNames = []struct {
+	state NeighborState
+	name  string
+}{
+	{NeighborStateIncomplete, "incomplete"},
+	{NeighborStateReachable, "reachable"},
+	{NeighborStateStale, "stale"},
+	{NeighborStateDelay, "delay"},
+	{NeighborStateProbe, "probe"},
+	{NeighborStateFailed, "failed"},
+	{NeighborStateNoARP, "noarp"},
+	{NeighborStatePermanent, "permanent"},
+}
+
+// String will return the name of the state as used by iproute2.
+func (s NeighborState) String() string {
+	var names []string
+	for _, n := range neighborStateNames {
+		if s&n.state != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// NeighborFlags are flags set on an entry in the ARP / NDP table.
+type NeighborFlags uint8
+
+const (
+	// NeighborFlagProxy marks the entry as a proxy ARP / NDP entry.
+	NeighborFlagProxy NeighborFlags = netlink.NTF_PROXY
+
+	// NeighborFlagRouter marks the IPv6 neighbor as a router.
+	NeighborFlagRouter NeighborFlags = netlink.NTF_ROUTER
+)
+
+// Neighbor is an entry in the ARP / NDP table of the Interface.
+type Neighbor struct {
+	// IP is the protocol address of the neighbor.
+	IP net.IP
+
+	// HardwareAddr is the MAC address of the neighbor. This may be nil
+	// if the neighbor has not been resolved.
+	HardwareAddr net.HardwareAddr
+
+	// State is the NUD state of the entry.
+	State NeighborState
+
+	// Flags are any flags set on the entry.
+	Flags NeighborFlags
+}
+
+// Neighbors will return all entries in the ARP / NDP table for this
+// Interface, including any proxy entries.
+func (i Interface) Neighbors() ([]Neighbor, error) {
+	iface := i.ps.link()
+
+	neighs, err := netlink.NeighList(iface.Attrs().Index, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies, err := netlink.NeighProxyList(iface.Attrs().Index, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	neighs = append(neighs, proxies...)
+
+	ret := make([]Neighbor, len(neighs))
+	for j, neigh := range neighs {
+		ret[j] = newNeighbor(neigh)
+	}
+	return ret, nil
+}
+
+func newNeighbor(neigh netlink.Neigh) Neighbor {
+	return Neighbor{
+		IP:           neigh.IP,
+		HardwareAddr: neigh.HardwareAddr,
+		State:        NeighborState(neigh.State),
+		Flags:        NeighborFlags(neigh.Flags),
+	}
+}
+
+// NeighborOptions controls the type of entry created in the ARP / NDP table
+// by AddNeighborWithOptions and ReplaceNeighborWithOptions.
+type NeighborOptions struct {
+	// State is the NUD state of the new entry, such as
+	// NeighborStateReachable, NeighborStateStale or NeighborStateNoARP.
+	// If unset, the entry will be NeighborStatePermanent.
+	State NeighborState
+
+	// Proxy will create a proxy ARP / NDP entry, which will cause the
+	// kernel to answer ARP requests or Neighbor Solicitations for the IP
+	// on this Interface. The hardware address may be nil for proxy
+	// entries. The kernel will only answer if proxy_arp or proxy_ndp
+	// is enabled on the Interface.
+	Proxy bool
+
+	// Router will set the router flag on the entry. This is only valid
+	// for IPv6 neighbors.
+	Router bool
+}
+
 // AddNeighbor will add an entry into the ARP / NDP table
 func (i Interface) AddNeighbor(mac net.HardwareAddr, ip net.IP) error {
+	return i.AddNeighborWithOptions(mac, ip, NeighborOptions{})
+}
+
+// AddNeighborWithOptions will add an entry into the ARP / NDP table, using
+// the provided NeighborOptions to control the state and flags of the entry.
+func (i Interface) AddNeighborWithOptions(
+	mac net.HardwareAddr,
+	ip net.IP,
+	opts NeighborOptions,
+) error {
+	neigh, err := i.newNeigh(mac, ip, opts)
+	if err != nil {
+		return err
+	}
+	return netlink.NeighAdd(neigh)
+}
+
+// ReplaceNeighbor will add an entry into the ARP / NDP table, or replace
+// the existing entry for the IP if one is already present.
+func (i Interface) ReplaceNeighbor(mac net.HardwareAddr, ip net.IP) error {
+	return i.ReplaceNeighborWithOptions(mac, ip, NeighborOptions{})
+}
+
+// ReplaceNeighborWithOptions will add an entry into the ARP / NDP table, or
+// replace the existing entry for the IP if one is already present, using the
+// provided NeighborOptions to control the state and flags of the entry.
+func (i Interface) ReplaceNeighborWithOptions(
+	mac net.HardwareAddr,
+	ip net.IP,
+	opts NeighborOptions,
+) error {
+	neigh, err := i.newNeigh(mac, ip, opts)
+	if err != nil {
+		return err
+	}
+	return netlink.NeighSet(neigh)
+}
+
+// RemoveNeighbor will remove the entry for the IP from the ARP / NDP table.
+func (i Interface) RemoveNeighbor(ip net.IP) error {
+	neigh, err := i.newNeigh(nil, ip, NeighborOptions{})
+	if err != nil {
+		return err
+	}
+	return netlink.NeighDel(neigh)
+}
+
+// RemoveProxyNeighbor will remove the proxy entry for the IP from the
+// ARP / NDP table.
+func (i Interface) RemoveProxyNeighbor(ip net.IP) error {
+	neigh, err := i.newNeigh(nil, ip, NeighborOptions{Proxy: true})
+	if err != nil {
+		return err
+	}
+	return netlink.NeighDel(neigh)
+}
+
+func (i Interface) newNeigh(
+	mac net.HardwareAddr,
+	ip net.IP,
+	opts NeighborOptions,
+) (*netlink.Neigh, error) {
+	var neigh *netlink.Neigh
 	if ip4 := ip.To4(); len(ip4) == net.IPv4len {
-		return i.addNeighborIPv4(mac, ip4)
+		if opts.Router {
+			return nil, fmt.Errorf("tap: router flag is only valid for IPv6 neighbors")
+		}
+		neigh = i.newNeighIPv4(mac, ip4)
+	} else {
+		neigh = i.newNeighIPv6(mac, ip)
+	}
+
+	if opts.State != 0 {
+		neigh.State = int(opts.State)
+	}
+	if opts.Proxy {
+		neigh.Flags |= netlink.NTF_PROXY
 	}
-	return i.addNeighborIPv6(mac, ip)
+	if opts.Router {
+		neigh.Flags |= netlink.NTF_ROUTER
+	}
+	return neigh, nil
 }
 
-func (i Interface) addNeighborIPv4(mac net.HardwareAddr, ip net.IP) error {
-	iface := i.ps.netif
+func (i Interface) newNeighIPv4(mac net.HardwareAddr, ip net.IP) *netlink.Neigh {
+	iface := i.ps.link()
 
-	return netlink.NeighAdd(&netlink.Neigh{
+	return &netlink.Neigh{
 		LinkIndex:    iface.Attrs().Index,
-		Type:         netlink.FAMILY_V4,
+		Family:       netlink.FAMILY_V4,
 		State:        netlink.NUD_PERMANENT,
 		IP:           ip,
 		HardwareAddr: mac,
-	})
+	}
 }
 
-func (i Interface) addNeighborIPv6(mac net.HardwareAddr, ip net.IP) error {
-	iface := i.ps.netif
+func (i Interface) newNeighIPv6(mac net.HardwareAddr, ip net.IP) *netlink.Neigh {
+	iface := i.ps.link()
 
-	return netlink.NeighAdd(&netlink.Neigh{
+	return &netlink.Neigh{
 		LinkIndex:    iface.Attrs().Index,
-		Type:         netlink.FAMILY_V6,
+		Family:       netlink.FAMILY_V6,
 		State:        netlink.NUD_PERMANENT,
 		IP:           ip,
 		HardwareAddr: mac,
-	})
+	}
 }
 
 // vim: foldmethod=marker
@@ -72,7 +72,7 @@ func newNeighRtMsg() ([]byte, *rtMsg) {
 }
 
 func (i Interface) addNeighborIPv4(mac net.HardwareAddr, ip net.IP) error {
-	netif := i.ps.netif
+	netif := i.ps.link()
 	rtMsgBytes, msg := newNeighRtMsg()
 	msg.hdr.Index = uint16(netif.Index)
 
@@ -106,7 +106,7 @@ func (i Interface) addNeighborIPv4(mac net.HardwareAddr, ip net.IP) error {
 }
 
 func (i Interface) addNeighborIPv6(mac net.HardwareAddr, ip net.IP) error {
-	netif := i.ps.netif
+	netif := i.ps.link()
 	rtMsgBytes, msg := newNeighRtMsg()
 	// msg.hdr.Index = uint16(netif.Index)
 
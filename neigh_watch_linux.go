@@ -0,0 +1,166 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// NeighborEventType is the type of change made to the ARP / NDP table.
+type NeighborEventType uint8
+
+const (
+	// NeighborEventAdd is sent when a new entry is added to the table.
+	NeighborEventAdd NeighborEventType = iota + 1
+
+	// NeighborEventUpdate is sent when an existing entry has changed,
+	// such as a change of state or hardware address.
+	NeighborEventUpdate
+
+	// NeighborEventDelete is sent when an entry is removed from the
+	// table.
+	NeighborEventDelete
+)
+
+// String will return a human readable name of the NeighborEventType.
+func (t NeighborEventType) String() string {
+	switch t {
+	case NeighborEventAdd:
+		return "add"
+	case NeighborEventUpdate:
+		return "update"
+	case NeighborEventDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("NeighborEventType(%d)", uint8(t))
+	}
+}
+
+// NeighborEvent is a change to the ARP / NDP table of the Interface.
+type NeighborEvent struct {
+	// Type is the type of change.
+	Type NeighborEventType
+
+	// Neighbor is the entry as it was after the change, or as it was
+	// when it was removed.
+	Neighbor Neighbor
+}
+
+// WatchNeighbors will return a channel of changes to the ARP / NDP table for
+// this Interface. The channel will be closed when the provided context is
+// cancelled, the Interface is closed, or the subscription fails.
+func (i Interface) WatchNeighbors(ctx context.Context) (<-chan NeighborEvent, error) {
+	index := i.ps.index()
+
+	updates, stop, err := subscribe(netlink.NeighSubscribe)
+	if err != nil {
+		return nil, err
+	}
+
+	// Since the kernel only tells us about new and deleted entries, we need
+	// to track what we've seen to know if a new entry is an update. We'll
+	// seed that from the current table after subscribing, so that nothing
+	// is missed in between. Anything that changes in that window will show
+	// up as an update rather than an add.
+	neighs, err := i.Neighbors()
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, neigh := range neighs {
+		known[neighborKey(neigh)] = true
+	}
+	events := make(chan NeighborEvent)
+
+	go func() {
+		defer func() {
+			close(events)
+			stop()
+		}()
+
+		for {
+			var update netlink.NeighUpdate
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-i.ctx.Done():
+				return
+			case update, ok = <-updates:
+				if !ok {
+					return
+				}
+			}
+
+			if update.LinkIndex != index {
+				continue
+			}
+
+			// If the Interface is on a bridge, we'll also see the bridge
+			// forwarding database entries for the port, which aren't ARP /
+			// NDP entries.
+			if update.Family != unix.AF_INET && update.Family != unix.AF_INET6 {
+				continue
+			}
+
+			ev := NeighborEvent{Neighbor: newNeighbor(update.Neigh)}
+			key := neighborKey(ev.Neighbor)
+
+			switch update.Type {
+			case unix.RTM_NEWNEIGH:
+				ev.Type = NeighborEventAdd
+				if known[key] {
+					ev.Type = NeighborEventUpdate
+				}
+				known[key] = true
+			case unix.RTM_DELNEIGH:
+				ev.Type = NeighborEventDelete
+				delete(known, key)
+			default:
+				continue
+			}
+
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			case <-i.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func neighborKey(neigh Neighbor) string {
+	return fmt.Sprintf("%s/%t", neigh.IP, neigh.Flags&NeighborFlagProxy != 0)
+}
+
+// vim: foldmethod=marker
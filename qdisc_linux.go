@@ -0,0 +1,440 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Qdisc is a queueing discipline which can be set as the root qdisc of the
+// Interface. This will be one of *Netem, *TBF or *FQCodel.
+type Qdisc interface {
+	// Kind will return the name of the qdisc, as used by `tc`.
+	Kind() string
+
+	// netlinkQdisc will return the netlink.Qdisc to send to the kernel.
+	netlinkQdisc(attrs netlink.QdiscAttrs) (netlink.Qdisc, error)
+}
+
+// Netem will emulate a WAN by delaying, dropping, duplicating, reordering
+// or corrupting packets. Percentages are from 0 to 100.
+type Netem struct {
+	// Latency is the delay added to each packet, plus or minus Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Limit is the number of packets which may be queued. If zero, the
+	// `tc` default of 1000 is used.
+	Limit uint32
+
+	Loss      float64
+	Duplicate float64
+	Corrupt   float64
+
+	// Reorder will send the percentage of packets immediately, with the
+	// rest delayed by Latency. This requires Latency to be set.
+	Reorder float64
+}
+
+// Kind will return "netem".
+func (n *Netem) Kind() string {
+	return "netem"
+}
+
+func (n *Netem) netlinkQdisc(attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	qdisc := &netlink.Netem{
+		QdiscAttrs:  attrs,
+		Latency:     durationToTicks(n.Latency),
+		Jitter:      durationToTicks(n.Jitter),
+		Limit:       n.Limit,
+		Loss:        percentToProbability(n.Loss),
+		Duplicate:   percentToProbability(n.Duplicate),
+		CorruptProb: percentToProbability(n.Corrupt),
+		ReorderProb: percentToProbability(n.Reorder),
+	}
+	if qdisc.Limit == 0 {
+		qdisc.Limit = 1000
+	}
+	if n.Reorder > 0 {
+		if n.Latency == 0 {
+			return nil, fmt.Errorf("tap: netem reordering requires latency")
+		}
+		qdisc.Gap = 1
+	}
+	return qdisc, nil
+}
+
+// TBF is a token bucket filter, which will limit the rate of packets sent
+// by the Interface.
+type TBF struct {
+	// Rate is the rate limit, in bytes per second.
+	Rate uint64
+
+	// Burst is the size of the bucket, in bytes. This must be at least
+	// the MTU of the Interface.
+	Burst uint32
+
+	// Limit is the number of bytes which may be queued waiting for tokens.
+	Limit uint32
+}
+
+// Kind will return "tbf".
+func (t *TBF) Kind() string {
+	return "tbf"
+}
+
+func (t *TBF) netlinkQdisc(attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	if t.Rate == 0 || t.Burst == 0 || t.Limit == 0 {
+		return nil, fmt.Errorf("tap: tbf requires a rate, burst and limit")
+	}
+	return &netlink.Tbf{
+		QdiscAttrs: attrs,
+		Rate:       t.Rate,
+		Limit:      t.Limit,
+		Buffer:     uint32(netlink.Xmittime(t.Rate, t.Burst)),
+	}, nil
+}
+
+// FQCodel is the Fair Queuing Controlled Delay qdisc. Any fields left as
+// zero (or nil) will use the kernel defaults.
+type FQCodel struct {
+	// Target is the acceptable queueing delay. This is returned by Qdisc,
+	// but can't be set by SetQdisc, which will return an error if it's
+	// set; the kernel default of 5ms is always used.
+	Target   time.Duration
+	Interval time.Duration
+
+	// Limit is the number of packets which may be queued.
+	Limit   uint32
+	Flows   uint32
+	Quantum uint32
+
+	// ECN will mark packets rather than dropping them, if the flow
+	// supports it. If nil, the kernel default (on) is used.
+	ECN *bool
+}
+
+// Kind will return "fq_codel".
+func (f *FQCodel) Kind() string {
+	return "fq_codel"
+}
+
+func (f *FQCodel) netlinkQdisc(attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	if f.Target != 0 {
+		return nil, fmt.Errorf("tap: can't set the fq_codel target")
+	}
+	// netlink.NewFqCodel starts from the kernel default for ECN, which is
+	// always sent.
+	qdisc := netlink.NewFqCodel(attrs)
+	qdisc.Interval = uint32(f.Interval.Microseconds())
+	qdisc.Limit = f.Limit
+	qdisc.Flows = f.Flows
+	qdisc.Quantum = f.Quantum
+	if f.ECN != nil && !*f.ECN {
+		qdisc.ECN = 0
+	}
+	return qdisc, nil
+}
+
+// QdiscStatistics are the kernel's counters for a qdisc.
+type QdiscStatistics struct {
+	Bytes      uint64
+	Packets    uint64
+	Drops      uint32
+	Overlimits uint32
+	Requeues   uint32
+
+	// Backlog is the number of bytes, and QueueLength the number of
+	// packets, currently queued.
+	Backlog     uint32
+	QueueLength uint32
+}
+
+// QdiscState is the root qdisc of the Interface.
+type QdiscState struct {
+	// Kind is the name of the qdisc, which may be a default qdisc set by
+	// the kernel, such as "pfifo_fast" or "noqueue".
+	Kind string
+
+	// Handle is the handle of the qdisc, which is zero for the default
+	// qdisc.
+	Handle uint32
+
+	// Qdisc is the configuration of the qdisc, or nil if the Kind isn't
+	// one of Netem, TBF or FQCodel.
+	Qdisc Qdisc
+
+	Stats QdiscStatistics
+}
+
+// SetQdisc will replace the root qdisc of the Interface.
+func (i Interface) SetQdisc(qdisc Qdisc) error {
+	q, err := qdisc.netlinkQdisc(netlink.QdiscAttrs{
+		LinkIndex: i.ps.index(),
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	if err != nil {
+		return err
+	}
+	return netlink.QdiscReplace(q)
+}
+
+// Qdisc will return the current root qdisc of the Interface, and its
+// statistics.
+func (i Interface) Qdisc() (*QdiscState, error) {
+	state, err := i.rootQdisc()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("tap: interface has no root qdisc")
+	}
+	return state, nil
+}
+
+// rootQdisc will return the root qdisc, or nil if there isn't one, which is
+// the case while the Interface is down.
+func (i Interface) rootQdisc() (*QdiscState, error) {
+	index := int32(i.ps.index())
+
+	req := nl.NewNetlinkRequest(unix.RTM_GETQDISC, unix.NLM_F_DUMP)
+	req.AddData(&nl.TcMsg{
+		Family:  nl.FAMILY_ALL,
+		Ifindex: index,
+	})
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWQDISC)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range msgs {
+		msg := nl.DeserializeTcMsg(m)
+		if msg.Ifindex != index || msg.Parent != netlink.HANDLE_ROOT {
+			continue
+		}
+		attrs, err := nl.ParseRouteAttr(m[msg.Len():])
+		if err != nil {
+			return nil, err
+		}
+		return newQdiscState(msg.Handle, attrs)
+	}
+	return nil, nil
+}
+
+// ResetQdisc will remove the root qdisc set by SetQdisc, restoring the
+// kernel default. This is a noop if the default qdisc is in use.
+func (i Interface) ResetQdisc() error {
+	state, err := i.rootQdisc()
+	if err != nil {
+		return err
+	}
+	if state == nil || state.Handle == 0 {
+		return nil
+	}
+
+	// netlink.QdiscDel only needs the QdiscAttrs, so the kind of qdisc
+	// doesn't matter here.
+	return netlink.QdiscDel(&netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: i.ps.index(),
+			Handle:    state.Handle,
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		QdiscType: state.Kind,
+	})
+}
+
+func newQdiscState(handle uint32, attrs []syscall.NetlinkRouteAttr) (*QdiscState, error) {
+	var (
+		state   = &QdiscState{Handle: handle}
+		options []byte
+		native  = nl.NativeEndian()
+	)
+
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case nl.TCA_KIND:
+			state.Kind = string(bytes.TrimRight(attr.Value, "\x00"))
+		case nl.TCA_OPTIONS:
+			options = attr.Value
+		case nl.TCA_STATS2:
+			stats, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, stat := range stats {
+				switch {
+				case stat.Attr.Type == nl.TCA_STATS_BASIC && len(stat.Value) >= 12:
+					state.Stats.Bytes = native.Uint64(stat.Value[0:8])
+					state.Stats.Packets = uint64(native.Uint32(stat.Value[8:12]))
+				case stat.Attr.Type == nl.TCA_STATS_QUEUE && len(stat.Value) >= 20:
+					state.Stats.QueueLength = native.Uint32(stat.Value[0:4])
+					state.Stats.Backlog = native.Uint32(stat.Value[4:8])
+					state.Stats.Drops = native.Uint32(stat.Value[8:12])
+					state.Stats.Requeues = native.Uint32(stat.Value[12:16])
+					state.Stats.Overlimits = native.Uint32(stat.Value[16:20])
+				}
+			}
+		}
+	}
+
+	var err error
+	switch state.Kind {
+	case "netem":
+		state.Qdisc, err = parseNetem(options)
+	case "tbf":
+		state.Qdisc, err = parseTBF(options)
+	case "fq_codel":
+		state.Qdisc, err = parseFQCodel(options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func parseNetem(options []byte) (*Netem, error) {
+	if len(options) < nl.SizeofTcNetemQopt {
+		return nil, fmt.Errorf("tap: netem options are too short")
+	}
+	opt := nl.DeserializeTcNetemQopt(options)
+	netem := &Netem{
+		Latency:   ticksToDuration(opt.Latency),
+		Jitter:    ticksToDuration(opt.Jitter),
+		Limit:     opt.Limit,
+		Loss:      probabilityToPercent(opt.Loss),
+		Duplicate: probabilityToPercent(opt.Duplicate),
+	}
+
+	attrs, err := nl.ParseRouteAttr(options[nl.SizeofTcNetemQopt:])
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case nl.TCA_NETEM_REORDER:
+			netem.Reorder = probabilityToPercent(nl.DeserializeTcNetemReorder(attr.Value).Probability)
+		case nl.TCA_NETEM_CORRUPT:
+			netem.Corrupt = probabilityToPercent(nl.DeserializeTcNetemCorrupt(attr.Value).Probability)
+		}
+	}
+	return netem, nil
+}
+
+func parseTBF(options []byte) (*TBF, error) {
+	attrs, err := nl.ParseRouteAttr(options)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tbf    = &TBF{}
+		buffer uint32
+	)
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case nl.TCA_TBF_PARMS:
+			opt := nl.DeserializeTcTbfQopt(attr.Value)
+			tbf.Rate = uint64(opt.Rate.Rate)
+			tbf.Limit = opt.Limit
+			buffer = opt.Buffer
+		case nl.TCA_TBF_RATE64:
+			tbf.Rate = nl.NativeEndian().Uint64(attr.Value)
+		}
+	}
+	// The kernel only gives us the bucket size as the time it takes to
+	// send a Burst at Rate, so convert it back to bytes.
+	tbf.Burst = uint32(math.Round(
+		float64(tbf.Rate) * ticksToDuration(buffer).Seconds(),
+	))
+	return tbf, nil
+}
+
+func parseFQCodel(options []byte) (*FQCodel, error) {
+	attrs, err := nl.ParseRouteAttr(options)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		fqCodel = &FQCodel{}
+		native  = nl.NativeEndian()
+	)
+	for _, attr := range attrs {
+		if len(attr.Value) < 4 {
+			continue
+		}
+		value := native.Uint32(attr.Value)
+		switch attr.Attr.Type {
+		case nl.TCA_FQ_CODEL_TARGET:
+			fqCodel.Target = time.Duration(value) * time.Microsecond
+		case nl.TCA_FQ_CODEL_INTERVAL:
+			fqCodel.Interval = time.Duration(value) * time.Microsecond
+		case nl.TCA_FQ_CODEL_LIMIT:
+			fqCodel.Limit = value
+		case nl.TCA_FQ_CODEL_FLOWS:
+			fqCodel.Flows = value
+		case nl.TCA_FQ_CODEL_QUANTUM:
+			fqCodel.Quantum = value
+		case nl.TCA_FQ_CODEL_ECN:
+			ecn := value != 0
+			fqCodel.ECN = &ecn
+		}
+	}
+	return fqCodel, nil
+}
+
+// durationToTicks will convert the duration to packet scheduler ticks, as
+// used by the kernel for netem and tbf.
+func durationToTicks(d time.Duration) uint32 {
+	return uint32(math.Round(float64(d.Microseconds()) * netlink.TickInUsec()))
+}
+
+func ticksToDuration(ticks uint32) time.Duration {
+	usec := float64(ticks) / netlink.TickInUsec()
+	return time.Duration(math.Round(usec)) * time.Microsecond
+}
+
+// percentToProbability will convert a percentage to the fraction of
+// MaxUint32 the kernel uses for probabilities.
+func percentToProbability(percent float64) uint32 {
+	if percent >= 100 {
+		return math.MaxUint32
+	}
+	if percent <= 0 {
+		return 0
+	}
+	return uint32(percent / 100 * math.MaxUint32)
+}
+
+func probabilityToPercent(prob uint32) float64 {
+	return float64(prob) / math.MaxUint32 * 100
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,189 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkQdisc(t *testing.T) {
+	var (
+		on    = true
+		off   = false
+		attrs = netlink.QdiscAttrs{LinkIndex: 5, Parent: netlink.HANDLE_ROOT}
+		codel = func(ecn uint32, limit uint32) *netlink.FqCodel {
+			q := netlink.NewFqCodel(attrs)
+			q.ECN = ecn
+			q.Limit = limit
+			return q
+		}
+	)
+
+	for _, tc := range []struct {
+		name  string
+		qdisc Qdisc
+		want  netlink.Qdisc
+		err   bool
+	}{
+		{
+			name:  "netem defaults",
+			qdisc: &Netem{},
+			want:  &netlink.Netem{QdiscAttrs: attrs, Limit: 1000},
+		},
+		{
+			name:  "netem loss",
+			qdisc: &Netem{Limit: 10, Loss: 100, Duplicate: 50},
+			want: &netlink.Netem{
+				QdiscAttrs: attrs,
+				Limit:      10,
+				Loss:       percentToProbability(100),
+				Duplicate:  percentToProbability(50),
+			},
+		},
+		{
+			name:  "netem reorder",
+			qdisc: &Netem{Latency: 10 * time.Millisecond, Reorder: 25},
+			want: &netlink.Netem{
+				QdiscAttrs:  attrs,
+				Latency:     durationToTicks(10 * time.Millisecond),
+				Limit:       1000,
+				Gap:         1,
+				ReorderProb: percentToProbability(25),
+			},
+		},
+		{
+			name:  "netem reorder without latency",
+			qdisc: &Netem{Reorder: 25},
+			err:   true,
+		},
+		{
+			name:  "tbf",
+			qdisc: &TBF{Rate: 125000, Burst: 3000, Limit: 10000},
+			want: &netlink.Tbf{
+				QdiscAttrs: attrs,
+				Rate:       125000,
+				Limit:      10000,
+				Buffer:     uint32(netlink.Xmittime(125000, 3000)),
+			},
+		},
+		{
+			name:  "tbf without burst",
+			qdisc: &TBF{Rate: 125000, Limit: 10000},
+			err:   true,
+		},
+		{
+			name:  "fq_codel defaults",
+			qdisc: &FQCodel{},
+			want:  codel(1, 0),
+		},
+		{
+			name:  "fq_codel ecn on",
+			qdisc: &FQCodel{ECN: &on, Limit: 100},
+			want:  codel(1, 100),
+		},
+		{
+			name:  "fq_codel ecn off",
+			qdisc: &FQCodel{ECN: &off},
+			want:  codel(0, 0),
+		},
+		{
+			name:  "fq_codel target",
+			qdisc: &FQCodel{Target: time.Millisecond},
+			err:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.qdisc.netlinkQdisc(attrs)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPercentToProbability(t *testing.T) {
+	for _, tc := range []struct {
+		percent float64
+		prob    uint32
+	}{
+		{-1, 0},
+		{0, 0},
+		{50, 1<<31 - 1},
+		{100, 1<<32 - 1},
+		{200, 1<<32 - 1},
+	} {
+		if prob := percentToProbability(tc.percent); prob != tc.prob {
+			t.Errorf("percentToProbability(%g) = %d, want %d", tc.percent, prob, tc.prob)
+		}
+	}
+}
+
+func TestSetQdisc(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+	if err := iface.SetUp(true); err != nil {
+		t.Fatal(err)
+	}
+
+	tbf := &TBF{Rate: 125000, Burst: 3000, Limit: 10000}
+	if err := iface.SetQdisc(tbf); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			t.Skip("tbf isn't available in this kernel")
+		}
+		t.Fatal(err)
+	}
+	state, err := iface.Qdisc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Qdisc, tbf) {
+		t.Fatalf("Qdisc() = %+v, want %+v", state.Qdisc, tbf)
+	}
+
+	if err := iface.ResetQdisc(); err != nil {
+		t.Fatal(err)
+	}
+	state, err = iface.Qdisc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Handle != 0 {
+		t.Fatalf("qdisc %q is still set after ResetQdisc", state.Kind)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,75 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Rename will change the name of the Interface. If the Interface is up, it
+// will be brought down for the rename, and back up once the rename is done.
+func (i Interface) Rename(name string) error {
+	if name == "" {
+		return fmt.Errorf("tap: interface name is empty")
+	}
+	if len(name) >= syscall.IFNAMSIZ {
+		return fmt.Errorf("tap: provided interface name is too long")
+	}
+
+	up, err := i.IsUp()
+	if err != nil {
+		return err
+	}
+	if up {
+		if err := i.SetUp(false); err != nil {
+			return err
+		}
+	}
+
+	if err := i.rename(name); err != nil {
+		if up {
+			// Try to put things back the way we found them, but the
+			// rename error is the one the caller wants.
+			i.SetUp(true)
+		}
+		return err
+	}
+
+	var ifname [syscall.IFNAMSIZ]byte
+	copy(ifname[:], name)
+	i.name.Store(&ifname)
+
+	// The link has been renamed, so even if we fail to update our state,
+	// we need to put the admin state back the way we found it.
+	err = i.Refresh()
+	if psErr := i.ps.rename(name); err == nil {
+		err = psErr
+	}
+	if up {
+		if upErr := i.SetUp(true); err == nil {
+			err = upErr
+		}
+	}
+	return err
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,301 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/ethernet"
+)
+
+var (
+	// ErrNoNeighbor will be returned by Resolver.Resolve if no reply was
+	// seen for the IP after every attempt was made.
+	ErrNoNeighbor = fmt.Errorf("tap: no reply from neighbor")
+)
+
+// ResolverOptions contains configuration for a Resolver.
+type ResolverOptions struct {
+	// HardwareAddr is the hardware address requests are sent from. This
+	// is required.
+	HardwareAddr net.HardwareAddr
+
+	// IPv4 is the sender address used in ARP requests. If unset, requests
+	// are sent as ARP probes, from 0.0.0.0.
+	IPv4 net.IP
+
+	// IPv6 is the source address used in Neighbor Solicitations. If unset,
+	// solicitations are sent from the unspecified address, and the reply
+	// will be sent to the all-nodes group.
+	IPv6 net.IP
+
+	// TTL is how long a resolved hardware address is cached for. If unset,
+	// this defaults to 30 seconds.
+	TTL time.Duration
+
+	// Attempts is the number of requests sent before giving up. If unset,
+	// this defaults to 3.
+	Attempts int
+
+	// Backoff is how long to wait for a reply to the first request. The
+	// wait is doubled after every attempt. If unset, this defaults to one
+	// second.
+	Backoff time.Duration
+}
+
+// Resolver will resolve the hardware address of an IP reachable over a TAP,
+// by sending ARP requests or Neighbor Solicitations, and waiting for the
+// reply. Resolved addresses are cached, and concurrent lookups for the same
+// IP will share a single set of requests.
+//
+// Resolver is itself a FrameReadWriter, and replies are only seen by calls to
+// Read, so something must be reading Frames from the Resolver for Resolve to
+// ever succeed. Any Frame that does not answer an outstanding Resolve will be
+// returned to the caller.
+type Resolver struct {
+	frames FrameReadWriter
+	opts   ResolverOptions
+
+	mu      sync.Mutex
+	cache   map[string]resolverEntry
+	pending map[string]*resolverCall
+}
+
+type resolverEntry struct {
+	mac     net.HardwareAddr
+	expires time.Time
+}
+
+type resolverCall struct {
+	done chan struct{}
+	mac  net.HardwareAddr
+	err  error
+}
+
+// NewResolver will create a new Resolver reading and writing Frames to the
+// provided FrameReadWriter, which is usually an Interface.
+func NewResolver(frames FrameReadWriter, opts ResolverOptions) (*Resolver, error) {
+	if len(opts.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("tap: resolver hardware address must be 48 bits")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	return &Resolver{
+		frames:  frames,
+		opts:    opts,
+		cache:   map[string]resolverEntry{},
+		pending: map[string]*resolverCall{},
+	}, nil
+}
+
+func resolverKey(ip net.IP) string {
+	return string(ip.To16())
+}
+
+// Resolve will return the hardware address of the IP, either from the cache,
+// or by sending requests and waiting for a reply.
+func (r *Resolver) Resolve(ctx context.Context, ip net.IP) (net.HardwareAddr, error) {
+	if ip.To16() == nil {
+		return nil, fmt.Errorf("tap: invalid IP address")
+	}
+	key := resolverKey(ip)
+
+	r.mu.Lock()
+	if entry, ok := r.cache[key]; ok && time.Now().Before(entry.expires) {
+		r.mu.Unlock()
+		return entry.mac, nil
+	}
+	call, ok := r.pending[key]
+	if !ok {
+		call = &resolverCall{done: make(chan struct{})}
+		r.pending[key] = call
+		go r.request(key, ip, call)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+		return call.mac, call.err
+	}
+}
+
+// Forget will remove the IP from the cache.
+func (r *Resolver) Forget(ip net.IP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, resolverKey(ip))
+}
+
+// request will send requests for the IP until the call is completed by a
+// reply, or every attempt has been made.
+func (r *Resolver) request(key string, ip net.IP, call *resolverCall) {
+	frame, err := r.requestFrame(ip)
+	if err != nil {
+		r.complete(key, nil, err)
+		return
+	}
+
+	wait := r.opts.Backoff
+	for attempt := 0; attempt < r.opts.Attempts; attempt++ {
+		if err := r.frames.Write(frame); err != nil {
+			r.complete(key, nil, err)
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-call.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+	r.complete(key, nil, ErrNoNeighbor)
+}
+
+// requestFrame will build the ARP request or Neighbor Solicitation for
+// the IP.
+func (r *Resolver) requestFrame(ip net.IP) (*ethernet.Frame, error) {
+	if ip4 := ip.To4(); len(ip4) == net.IPv4len {
+		sender := r.opts.IPv4
+		if sender == nil {
+			sender = net.IPv4zero
+		}
+		req := arpPacket{
+			Operation:          arpOperationRequest,
+			SenderHardwareAddr: r.opts.HardwareAddr,
+			SenderIP:           sender,
+			TargetHardwareAddr: net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			TargetIP:           ip4,
+		}
+		return req.frame(ethernet.Broadcast)
+	}
+
+	var (
+		src = r.opts.IPv6
+		dst = ipv6SolicitedNodeMulticast(ip)
+		ns  = ndpNeighborSolicitation{Target: ip}
+	)
+	if src == nil {
+		// The Source Link-Layer Address option must not be included
+		// when the source is the unspecified address.
+		src = net.IPv6unspecified
+	} else {
+		ns.SourceLinkLayerAddr = r.opts.HardwareAddr
+	}
+	msg, err := ns.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return icmpv6Frame(r.opts.HardwareAddr, ipv6MulticastMAC(dst), src, dst, msg), nil
+}
+
+// complete will finish the outstanding call for the key, if there is one,
+// and cache the result if it was successful. This returns true if there
+// was an outstanding call.
+func (r *Resolver) complete(key string, mac net.HardwareAddr, err error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call, ok := r.pending[key]
+	if !ok {
+		return false
+	}
+	delete(r.pending, key)
+
+	if err == nil {
+		r.cache[key] = resolverEntry{
+			mac:     mac,
+			expires: time.Now().Add(r.opts.TTL),
+		}
+	}
+	call.mac, call.err = mac, err
+	close(call.done)
+	return true
+}
+
+// Read will read Frames from the underlying FrameReadWriter, completing any
+// outstanding calls to Resolve, and returning the next Frame that was not
+// handled.
+func (r *Resolver) Read() (*ethernet.Frame, error) {
+	for {
+		frame, err := r.frames.Read()
+		if err != nil {
+			return nil, err
+		}
+		if !r.handle(frame) {
+			return frame, nil
+		}
+	}
+}
+
+// Write will write the Frame to the underlying FrameReadWriter.
+func (r *Resolver) Write(frame *ethernet.Frame) error {
+	return r.frames.Write(frame)
+}
+
+// handle will complete any outstanding call that the Frame is a reply to,
+// returning true if the Frame was consumed.
+func (r *Resolver) handle(frame *ethernet.Frame) bool {
+	switch frame.EtherType {
+	case ethernet.EtherTypeARP:
+		var reply arpPacket
+		if err := reply.UnmarshalBinary(frame.Payload); err != nil {
+			return false
+		}
+		if reply.Operation != arpOperationReply {
+			return false
+		}
+		return r.complete(resolverKey(reply.SenderIP), reply.SenderHardwareAddr, nil)
+	case ethernet.EtherTypeIPv6:
+		_, _, msg, err := parseICMPv6(frame)
+		if err != nil {
+			return false
+		}
+		var na ndpNeighborAdvertisement
+		if err := na.UnmarshalBinary(msg); err != nil {
+			return false
+		}
+		mac := na.TargetLinkLayerAddr
+		if mac == nil {
+			mac = frame.Source
+		}
+		return r.complete(resolverKey(na.Target), mac, nil)
+	default:
+		return false
+	}
+}
+
+// vim: foldmethod=marker
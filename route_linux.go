@@ -0,0 +1,197 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// RouteScope is the scope of a Route, which is the distance to the
+// destination.
+type RouteScope uint8
+
+const (
+	// RouteScopeUniverse is a route to a destination anywhere.
+	RouteScopeUniverse RouteScope = unix.RT_SCOPE_UNIVERSE
+
+	// RouteScopeSite is a route to a destination within the site.
+	RouteScopeSite RouteScope = unix.RT_SCOPE_SITE
+
+	// RouteScopeLink is a route to a destination directly attached to
+	// the link.
+	RouteScopeLink RouteScope = unix.RT_SCOPE_LINK
+
+	// RouteScopeHost is a route to a destination on this host.
+	RouteScopeHost RouteScope = unix.RT_SCOPE_HOST
+)
+
+// Route is an entry in a routing table that sends traffic out of the
+// Interface.
+type Route struct {
+	// Destination is the prefix being routed. The default route is
+	// 0.0.0.0/0 or ::/0. Destination may also be left nil for the default
+	// route if the Gateway or Source says which family it's for.
+	Destination *net.IPNet
+
+	// Gateway is the next hop for the Destination. If nil, the Destination
+	// is directly reachable over the Interface.
+	Gateway net.IP
+
+	// Source is the preferred source address when sending to the
+	// Destination.
+	Source net.IP
+
+	// Metric is the priority of the Route; lower metrics are preferred.
+	Metric int
+
+	// Scope is the scope of the Route.
+	Scope RouteScope
+
+	// Table is the routing table the Route is in. If unset, the main
+	// table is used.
+	Table int
+}
+
+// AddRoute will add the Route through this Interface.
+func (i Interface) AddRoute(route Route) error {
+	r, err := i.newRoute(route)
+	if err != nil {
+		return err
+	}
+	return netlink.RouteAdd(r)
+}
+
+// ReplaceRoute will add the Route through this Interface, or replace the
+// existing Route to the same Destination.
+func (i Interface) ReplaceRoute(route Route) error {
+	r, err := i.newRoute(route)
+	if err != nil {
+		return err
+	}
+	return netlink.RouteReplace(r)
+}
+
+// RemoveRoute will remove the Route through this Interface.
+func (i Interface) RemoveRoute(route Route) error {
+	r, err := i.newRoute(route)
+	if err != nil {
+		return err
+	}
+	return netlink.RouteDel(r)
+}
+
+// Routes will return the Routes that send traffic out of this Interface, in
+// the main routing table and any of the provided tables. Only unicast routes
+// are returned; the local and broadcast routes the kernel adds for the
+// Interface's own addresses are not, since removing them would break
+// delivery to those addresses.
+func (i Interface) Routes(tables ...int) ([]Route, error) {
+	wanted := map[int]bool{unix.RT_TABLE_MAIN: true}
+	for _, table := range tables {
+		wanted[table] = true
+	}
+
+	var ret []Route
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := netlink.RouteListFiltered(
+			family,
+			&netlink.Route{
+				LinkIndex: i.ps.index(),
+				Table:     unix.RT_TABLE_UNSPEC,
+				Type:      unix.RTN_UNICAST,
+			},
+			netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE|netlink.RT_FILTER_TYPE,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range routes {
+			if !wanted[r.Table] {
+				continue
+			}
+			dst := r.Dst
+			if dst == nil {
+				// netlink gives us a nil Dst for the default route, which
+				// doesn't say which family it's for, so we'll fill it in
+				// so it can be passed back to RemoveRoute.
+				dst = defaultRoute(family)
+			}
+			ret = append(ret, Route{
+				Destination: dst,
+				Gateway:     r.Gw,
+				Source:      r.Src,
+				Metric:      r.Priority,
+				Scope:       RouteScope(r.Scope),
+				Table:       r.Table,
+			})
+		}
+	}
+	return ret, nil
+}
+
+// defaultRoute will return the default route prefix for the family.
+func defaultRoute(family int) *net.IPNet {
+	if family == netlink.FAMILY_V4 {
+		return &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	}
+	return &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+}
+
+func (i Interface) newRoute(route Route) (*netlink.Route, error) {
+	dst := route.Destination
+	if dst == nil {
+		// netlink will treat a nil Dst as the default route, but needs to
+		// know which family's default route we mean.
+		var ip net.IP
+		switch {
+		case route.Gateway != nil:
+			ip = route.Gateway
+		case route.Source != nil:
+			ip = route.Source
+		default:
+			return nil, fmt.Errorf("tap: default route needs a gateway or source, or a destination of 0.0.0.0/0 or ::/0")
+		}
+		if ip4 := ip.To4(); len(ip4) == net.IPv4len {
+			dst = defaultRoute(netlink.FAMILY_V4)
+		} else {
+			dst = defaultRoute(netlink.FAMILY_V6)
+		}
+	}
+
+	return &netlink.Route{
+		LinkIndex: i.ps.index(),
+		Dst:       dst,
+		Gw:        route.Gateway,
+		Src:       route.Source,
+		Priority:  route.Metric,
+		Scope:     netlink.Scope(route.Scope),
+		Table:     route.Table,
+	}, nil
+}
+
+// vim: foldmethod=marker
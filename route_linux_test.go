@@ -0,0 +1,96 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestRoutes(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+	if err := iface.SetUp(true); err != nil {
+		t.Fatal(err)
+	}
+	ip, network, _ := net.ParseCIDR("10.78.0.1/24")
+	if err := iface.AddAddr(ip, network); err != nil {
+		t.Fatal(err)
+	}
+
+	_, v4, _ := net.ParseCIDR("0.0.0.0/0")
+	_, v6, _ := net.ParseCIDR("::/0")
+	for _, route := range []Route{
+		{Destination: v4, Metric: 4242},
+		{Destination: v6, Table: 100},
+		{Gateway: net.IPv4(10, 78, 0, 2), Table: 100},
+	} {
+		if err := iface.AddRoute(route); err != nil {
+			t.Fatalf("AddRoute(%+v): %s", route, err)
+		}
+	}
+
+	routes, err := iface.Routes(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults int
+	for _, route := range routes {
+		if route.Table == unix.RT_TABLE_LOCAL {
+			t.Errorf("Routes returned a local table route: %+v", route)
+		}
+		if ones, _ := route.Destination.Mask.Size(); ones == 0 {
+			defaults++
+		}
+	}
+	if defaults != 3 {
+		t.Fatalf("Routes returned %d default routes, want 3: %+v", defaults, routes)
+	}
+
+	// Everything Routes returns must be removable as-is.
+	for _, route := range routes {
+		if err := iface.RemoveRoute(route); err != nil {
+			t.Fatalf("RemoveRoute(%+v): %s", route, err)
+		}
+	}
+	routes, err = iface.Routes(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 0 {
+		t.Fatalf("routes left after removing them all: %+v", routes)
+	}
+
+	// Local delivery to our own address must still work.
+	local, err := netlink.RouteGet(ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(local) != 1 || local[0].Type != unix.RTN_LOCAL {
+		t.Fatalf("route to %s isn't local: %+v", ip, local)
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,272 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"net"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Rule is a policy routing rule (as managed by `ip rule`) that is owned by
+// the Interface. Rules are removed when the Interface is closed.
+//
+// If neither Source nor Destination are set, the Rule will be created for
+// both IPv4 and IPv6.
+type Rule struct {
+	// Priority is the priority of the Rule; lower priorities are matched
+	// first. If unset, the kernel will pick a priority.
+	Priority int
+
+	// Table is the routing table to look up when the Rule matches.
+	Table int
+
+	// Input will match packets that arrived on this Interface.
+	Input bool
+
+	// Output will match packets that are being sent out of this Interface.
+	Output bool
+
+	// Mark will match packets with this firewall mark. If unset, the
+	// firewall mark is not matched.
+	Mark uint32
+
+	// Mask is the mask applied to the firewall mark before it is compared
+	// to Mark. If unset, the whole mark is compared.
+	Mask uint32
+
+	// Source will match packets from this prefix.
+	Source *net.IPNet
+
+	// Destination will match packets to this prefix.
+	Destination *net.IPNet
+}
+
+// ruleTracker keeps track of every rule added through an Interface.
+type ruleTracker struct {
+	mu    sync.Mutex
+	rules []*netlink.Rule
+}
+
+func ruleEqual(a, b *netlink.Rule) bool {
+	prefixEqual := func(a, b *net.IPNet) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.String() == b.String()
+	}
+
+	return a.Priority == b.Priority &&
+		a.Family == b.Family &&
+		a.Table == b.Table &&
+		a.Mark == b.Mark &&
+		a.Mask == b.Mask &&
+		a.IifName == b.IifName &&
+		a.OifName == b.OifName &&
+		prefixEqual(a.Src, b.Src) &&
+		prefixEqual(a.Dst, b.Dst)
+}
+
+func (rt *ruleTracker) add(rule *netlink.Rule) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.rules = append(rt.rules, rule)
+}
+
+func (rt *ruleTracker) remove(rule *netlink.Rule) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for j, r := range rt.rules {
+		if ruleEqual(r, rule) {
+			rt.rules = append(rt.rules[:j], rt.rules[j+1:]...)
+			return
+		}
+	}
+}
+
+// rename will re-create every tracked rule that matches on the name of the
+// Interface. The kernel matches iif and oif rules by name, so they don't
+// follow the Interface when it's renamed. Rules that can't be re-created
+// are no longer tracked, and the first error encountered is returned.
+func (rt *ruleTracker) rename(name string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var (
+		ret   error
+		rules []*netlink.Rule
+	)
+	for _, rule := range rt.rules {
+		if rule.IifName == "" && rule.OifName == "" {
+			rules = append(rules, rule)
+			continue
+		}
+
+		next := *rule
+		if next.IifName != "" {
+			next.IifName = name
+		}
+		if next.OifName != "" {
+			next.OifName = name
+		}
+
+		if err := netlink.RuleDel(rule); err != nil {
+			if ret == nil {
+				ret = err
+			}
+			rules = append(rules, rule)
+			continue
+		}
+		if err := netlink.RuleAdd(&next); err != nil {
+			if ret == nil {
+				ret = err
+			}
+			continue
+		}
+		rules = append(rules, &next)
+	}
+	rt.rules = rules
+	return ret
+}
+
+// close will remove every tracked rule from the kernel, returning the first
+// error encountered.
+func (rt *ruleTracker) close() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var ret error
+	for _, rule := range rt.rules {
+		if err := netlink.RuleDel(rule); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	rt.rules = nil
+	return ret
+}
+
+// AddRule will add a policy routing Rule. The Rule will be removed when the
+// Interface is closed, or its Context is cancelled.
+func (i Interface) AddRule(rule Rule) error {
+	rules := i.newRules(rule)
+	for j, r := range rules {
+		if err := netlink.RuleAdd(r); err != nil {
+			// Don't leave half of a dual-stack Rule behind.
+			for _, added := range rules[:j] {
+				netlink.RuleDel(added)
+			}
+			return err
+		}
+	}
+	for _, r := range rules {
+		i.ps.rules.add(r)
+	}
+	return nil
+}
+
+// RemoveRule will remove a policy routing Rule that was added with AddRule.
+func (i Interface) RemoveRule(rule Rule) error {
+	for _, r := range i.newRules(rule) {
+		if err := netlink.RuleDel(r); err != nil {
+			return err
+		}
+		i.ps.rules.remove(r)
+	}
+	return nil
+}
+
+// Rules will return the policy routing Rules added with AddRule that have
+// not yet been removed. Dual-stack Rules will be returned once per family.
+func (i Interface) Rules() []Rule {
+	i.ps.rules.mu.Lock()
+	defer i.ps.rules.mu.Unlock()
+
+	ret := make([]Rule, len(i.ps.rules.rules))
+	for j, r := range i.ps.rules.rules {
+		rule := Rule{
+			Table:       r.Table,
+			Input:       r.IifName != "",
+			Output:      r.OifName != "",
+			Source:      r.Src,
+			Destination: r.Dst,
+		}
+		if r.Priority >= 0 {
+			rule.Priority = r.Priority
+		}
+		if r.Mark >= 0 {
+			rule.Mark = uint32(r.Mark)
+		}
+		if r.Mask >= 0 {
+			rule.Mask = uint32(r.Mask)
+		}
+		ret[j] = rule
+	}
+	return ret
+}
+
+// newRules will return the netlink rules for the Rule; one per family.
+func (i Interface) newRules(rule Rule) []*netlink.Rule {
+	base := netlink.NewRule()
+	base.Table = rule.Table
+	base.Src = rule.Source
+	base.Dst = rule.Destination
+	if rule.Priority != 0 {
+		base.Priority = rule.Priority
+	}
+	if rule.Input {
+		base.IifName = i.Name()
+	}
+	if rule.Output {
+		base.OifName = i.Name()
+	}
+	if rule.Mark != 0 {
+		base.Mark = int(rule.Mark)
+		if rule.Mask != 0 {
+			base.Mask = int(rule.Mask)
+		}
+	}
+
+	var prefix *net.IPNet
+	switch {
+	case rule.Source != nil:
+		prefix = rule.Source
+	case rule.Destination != nil:
+		prefix = rule.Destination
+	}
+
+	if prefix != nil {
+		base.Family = netlink.FAMILY_V6
+		if ip4 := prefix.IP.To4(); len(ip4) == net.IPv4len {
+			base.Family = netlink.FAMILY_V4
+		}
+		return []*netlink.Rule{base}
+	}
+
+	v6 := *base
+	base.Family = netlink.FAMILY_V4
+	v6.Family = netlink.FAMILY_V6
+	return []*netlink.Rule{base, &v6}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,220 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"net"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// namedInterface will return an Interface with only a name, which is enough
+// for code that builds requests but doesn't send them.
+func namedInterface(name string) Interface {
+	var ifname [syscall.IFNAMSIZ]byte
+	copy(ifname[:], name)
+	i := Interface{name: &atomic.Pointer[[syscall.IFNAMSIZ]byte]{}}
+	i.name.Store(&ifname)
+	return i
+}
+
+func TestRuleEqual(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("192.0.2.0/24")
+	_, v4Copy, _ := net.ParseCIDR("192.0.2.0/24")
+	_, other, _ := net.ParseCIDR("198.51.100.0/24")
+
+	base := func() *netlink.Rule {
+		r := netlink.NewRule()
+		r.Family = netlink.FAMILY_V4
+		r.Table = 100
+		r.IifName = "tap0"
+		r.Src = v4
+		return r
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*netlink.Rule)
+		equal  bool
+	}{
+		{"same", func(r *netlink.Rule) {}, true},
+		{"same prefix, different pointer", func(r *netlink.Rule) { r.Src = v4Copy }, true},
+		{"priority", func(r *netlink.Rule) { r.Priority = 10 }, false},
+		{"family", func(r *netlink.Rule) { r.Family = netlink.FAMILY_V6 }, false},
+		{"table", func(r *netlink.Rule) { r.Table = 101 }, false},
+		{"mark", func(r *netlink.Rule) { r.Mark = 1 }, false},
+		{"mask", func(r *netlink.Rule) { r.Mask = 0xff }, false},
+		{"iif", func(r *netlink.Rule) { r.IifName = "tap1" }, false},
+		{"oif", func(r *netlink.Rule) { r.OifName = "tap0" }, false},
+		{"source", func(r *netlink.Rule) { r.Src = other }, false},
+		{"no source", func(r *netlink.Rule) { r.Src = nil }, false},
+		{"destination", func(r *netlink.Rule) { r.Dst = v4 }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := base(), base()
+			tc.change(b)
+			if got := ruleEqual(a, b); got != tc.equal {
+				t.Fatalf("ruleEqual = %t, want %t", got, tc.equal)
+			}
+			if got := ruleEqual(b, a); got != tc.equal {
+				t.Fatalf("ruleEqual is not symmetric")
+			}
+		})
+	}
+}
+
+func TestNewRules(t *testing.T) {
+	iface := namedInterface("tap7")
+	_, v4, _ := net.ParseCIDR("192.0.2.0/24")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+
+	rule := func(family int, change func(*netlink.Rule)) *netlink.Rule {
+		r := netlink.NewRule()
+		r.Family = family
+		r.Table = 100
+		change(r)
+		return r
+	}
+
+	for _, tc := range []struct {
+		name string
+		rule Rule
+		want []*netlink.Rule
+	}{
+		{
+			name: "dual stack input",
+			rule: Rule{Table: 100, Input: true, Priority: 1000},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V4, func(r *netlink.Rule) { r.IifName = "tap7"; r.Priority = 1000 }),
+				rule(netlink.FAMILY_V6, func(r *netlink.Rule) { r.IifName = "tap7"; r.Priority = 1000 }),
+			},
+		},
+		{
+			name: "ipv4 source output",
+			rule: Rule{Table: 100, Output: true, Source: v4},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V4, func(r *netlink.Rule) { r.OifName = "tap7"; r.Src = v4 }),
+			},
+		},
+		{
+			name: "ipv6 destination",
+			rule: Rule{Table: 100, Destination: v6},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V6, func(r *netlink.Rule) { r.Dst = v6 }),
+			},
+		},
+		{
+			name: "mark without mask",
+			rule: Rule{Table: 100, Mark: 7, Source: v4},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V4, func(r *netlink.Rule) { r.Mark = 7; r.Src = v4 }),
+			},
+		},
+		{
+			name: "mask without mark",
+			rule: Rule{Table: 100, Mask: 0xff, Source: v4},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V4, func(r *netlink.Rule) { r.Src = v4 }),
+			},
+		},
+		{
+			name: "mark and mask",
+			rule: Rule{Table: 100, Mark: 7, Mask: 0xff, Source: v4},
+			want: []*netlink.Rule{
+				rule(netlink.FAMILY_V4, func(r *netlink.Rule) { r.Mark = 7; r.Mask = 0xff; r.Src = v4 }),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := iface.newRules(tc.rule)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleTracker(t *testing.T) {
+	iface := namedInterface("tap7")
+	rt := &ruleTracker{}
+	for _, r := range iface.newRules(Rule{Table: 100, Input: true}) {
+		rt.add(r)
+	}
+	if len(rt.rules) != 2 {
+		t.Fatalf("tracking %d rules, want 2", len(rt.rules))
+	}
+
+	// Removing a rule that was built separately must still find it.
+	rt.remove(iface.newRules(Rule{Table: 100, Input: true})[1])
+	if len(rt.rules) != 1 || rt.rules[0].Family != netlink.FAMILY_V4 {
+		t.Fatalf("tracking %+v, want only the IPv4 rule", rt.rules)
+	}
+	rt.remove(iface.newRules(Rule{Table: 101, Input: true})[0])
+	if len(rt.rules) != 1 {
+		t.Fatal("removed a rule for another table")
+	}
+}
+
+// hasRule will return true if the kernel has a rule for the table that
+// matches on the interface name.
+func hasRule(t *testing.T, table int, name string) bool {
+	t.Helper()
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rules {
+		if r.Table == table && r.IifName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRulesRemovedOnClose(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+	const table = 4242
+
+	if err := iface.AddRule(Rule{Table: table, Input: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := iface.Rules(); len(got) != 2 {
+		t.Fatalf("Rules() = %+v, want one per family", got)
+	}
+	if !hasRule(t, table, iface.Name()) {
+		t.Fatal("rule wasn't added")
+	}
+
+	if err := iface.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if hasRule(t, table, iface.Name()) {
+		t.Fatal("rule is still there after Close")
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,110 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Statistics are the kernel's counters for the Interface.
+//
+// These are from the point of view of the kernel, so "rx" is traffic written
+// to the TAP by userspace, and "tx" is traffic sent by the kernel to be read
+// by userspace. TxDropped will increase if the reader of the TAP is not
+// keeping up with the kernel.
+type Statistics struct {
+	RxPackets uint64
+	TxPackets uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+
+	// Multicast is the number of multicast packets received.
+	Multicast  uint64
+	Collisions uint64
+
+	TxCarrierErrors uint64
+
+	// CarrierChanges is the number of times the carrier has gone up or
+	// down, and CarrierUp and CarrierDown are the number of times it has
+	// gone up and down respectively.
+	CarrierChanges uint32
+	CarrierUp      uint32
+	CarrierDown    uint32
+}
+
+// Stats will return the kernel's current counters for the Interface.
+func (i Interface) Stats() (*Statistics, error) {
+	attrs, err := i.linkAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stats   = &Statistics{}
+		stats64 = netlink.LinkStatistics64{}
+		native  = nl.NativeEndian()
+	)
+
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.IFLA_STATS64:
+			// Newer kernels have grown this struct; anything past what
+			// we know about is ignored.
+			buf := make([]byte, binary.Size(stats64))
+			copy(buf, attr.Value)
+			if err := binary.Read(bytes.NewReader(buf), native, &stats64); err != nil {
+				return nil, err
+			}
+		case unix.IFLA_CARRIER_CHANGES:
+			stats.CarrierChanges = native.Uint32(attr.Value)
+		case unix.IFLA_CARRIER_UP_COUNT:
+			stats.CarrierUp = native.Uint32(attr.Value)
+		case unix.IFLA_CARRIER_DOWN_COUNT:
+			stats.CarrierDown = native.Uint32(attr.Value)
+		}
+	}
+
+	stats.RxPackets = stats64.RxPackets
+	stats.TxPackets = stats64.TxPackets
+	stats.RxBytes = stats64.RxBytes
+	stats.TxBytes = stats64.TxBytes
+	stats.RxErrors = stats64.RxErrors
+	stats.TxErrors = stats64.TxErrors
+	stats.RxDropped = stats64.RxDropped
+	stats.TxDropped = stats64.TxDropped
+	stats.Multicast = stats64.Multicast
+	stats.Collisions = stats64.Collisions
+	stats.TxCarrierErrors = stats64.TxCarrierErrors
+	return stats, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,269 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RPFilter controls reverse path filtering (rp_filter) on the Interface.
+type RPFilter uint8
+
+const (
+	// RPFilterOff will not validate the source address of packets.
+	RPFilterOff RPFilter = 0
+
+	// RPFilterStrict will drop packets if the route back to the source
+	// address is not out of the Interface, as defined in RFC 3704.
+	RPFilterStrict RPFilter = 1
+
+	// RPFilterLoose will drop packets if the source address is not
+	// reachable through any interface.
+	RPFilterLoose RPFilter = 2
+)
+
+// ARPIgnore controls which ARP requests the kernel will reply to on the
+// Interface (arp_ignore).
+type ARPIgnore uint8
+
+const (
+	// ARPIgnoreAny will reply for any local address, configured on any
+	// interface. This is the kernel default.
+	ARPIgnoreAny ARPIgnore = 0
+
+	// ARPIgnoreInterface will only reply if the target address is
+	// configured on the Interface.
+	ARPIgnoreInterface ARPIgnore = 1
+
+	// ARPIgnoreSubnet will only reply if the target address is configured
+	// on the Interface, and the sender is in the same subnet.
+	ARPIgnoreSubnet ARPIgnore = 2
+
+	// ARPIgnoreAll will not reply to any ARP requests.
+	ARPIgnoreAll ARPIgnore = 8
+)
+
+// ARPAnnounce controls which source address the kernel will use in ARP
+// requests sent on the Interface (arp_announce).
+type ARPAnnounce uint8
+
+const (
+	// ARPAnnounceAny will use any local address. This is the kernel
+	// default.
+	ARPAnnounceAny ARPAnnounce = 0
+
+	// ARPAnnounceSubnet will try to avoid addresses not in the target's
+	// subnet.
+	ARPAnnounceSubnet ARPAnnounce = 1
+
+	// ARPAnnounceBest will always use the best address configured on the
+	// Interface for the target.
+	ARPAnnounceBest ARPAnnounce = 2
+)
+
+// AcceptRA controls if the kernel will accept IPv6 Router Advertisements
+// on the Interface (accept_ra).
+type AcceptRA uint8
+
+const (
+	// AcceptRANever will not accept Router Advertisements.
+	AcceptRANever AcceptRA = 0
+
+	// AcceptRAUnlessForwarding will accept Router Advertisements if
+	// forwarding is disabled. This is the kernel default.
+	AcceptRAUnlessForwarding AcceptRA = 1
+
+	// AcceptRAAlways will accept Router Advertisements even if forwarding
+	// is enabled.
+	AcceptRAAlways AcceptRA = 2
+)
+
+// SetIPv4Forwarding will enable or disable IPv4 forwarding on the Interface.
+func (i Interface) SetIPv4Forwarding(forwarding bool) error {
+	return i.writeSysctlBool("ipv4", "forwarding", forwarding)
+}
+
+// IPv4Forwarding will return true if IPv4 forwarding is enabled on the
+// Interface.
+func (i Interface) IPv4Forwarding() (bool, error) {
+	return i.readSysctlBool("ipv4", "forwarding")
+}
+
+// SetIPv6Forwarding will enable or disable IPv6 forwarding on the Interface.
+func (i Interface) SetIPv6Forwarding(forwarding bool) error {
+	return i.writeSysctlBool("ipv6", "forwarding", forwarding)
+}
+
+// IPv6Forwarding will return true if IPv6 forwarding is enabled on the
+// Interface.
+func (i Interface) IPv6Forwarding() (bool, error) {
+	return i.readSysctlBool("ipv6", "forwarding")
+}
+
+// SetRPFilter will set the reverse path filtering mode of the Interface.
+// The kernel will use the higher of this and the "all" value.
+func (i Interface) SetRPFilter(mode RPFilter) error {
+	return i.writeSysctlUint("ipv4", "rp_filter", uint64(mode))
+}
+
+// RPFilter will return the reverse path filtering mode of the Interface.
+func (i Interface) RPFilter() (RPFilter, error) {
+	v, err := i.readSysctlUint("ipv4", "rp_filter")
+	return RPFilter(v), err
+}
+
+// SetProxyARP will enable or disable replying to ARP requests on behalf
+// of addresses routed through other interfaces.
+func (i Interface) SetProxyARP(proxy bool) error {
+	return i.writeSysctlBool("ipv4", "proxy_arp", proxy)
+}
+
+// ProxyARP will return true if proxy ARP is enabled on the Interface.
+func (i Interface) ProxyARP() (bool, error) {
+	return i.readSysctlBool("ipv4", "proxy_arp")
+}
+
+// SetARPIgnore will set which ARP requests the kernel replies to on the
+// Interface.
+func (i Interface) SetARPIgnore(mode ARPIgnore) error {
+	return i.writeSysctlUint("ipv4", "arp_ignore", uint64(mode))
+}
+
+// ARPIgnore will return which ARP requests the kernel replies to on the
+// Interface.
+func (i Interface) ARPIgnore() (ARPIgnore, error) {
+	v, err := i.readSysctlUint("ipv4", "arp_ignore")
+	return ARPIgnore(v), err
+}
+
+// SetARPAnnounce will set which source address the kernel uses in ARP
+// requests sent on the Interface.
+func (i Interface) SetARPAnnounce(mode ARPAnnounce) error {
+	return i.writeSysctlUint("ipv4", "arp_announce", uint64(mode))
+}
+
+// ARPAnnounce will return which source address the kernel uses in ARP
+// requests sent on the Interface.
+func (i Interface) ARPAnnounce() (ARPAnnounce, error) {
+	v, err := i.readSysctlUint("ipv4", "arp_announce")
+	return ARPAnnounce(v), err
+}
+
+// SetAcceptRA will set if the kernel accepts IPv6 Router Advertisements on
+// the Interface.
+func (i Interface) SetAcceptRA(mode AcceptRA) error {
+	return i.writeSysctlUint("ipv6", "accept_ra", uint64(mode))
+}
+
+// AcceptRA will return if the kernel accepts IPv6 Router Advertisements on
+// the Interface.
+func (i Interface) AcceptRA() (AcceptRA, error) {
+	v, err := i.readSysctlUint("ipv6", "accept_ra")
+	return AcceptRA(v), err
+}
+
+// SetDisableIPv6 will disable (or re-enable) IPv6 on the Interface. This
+// will remove all IPv6 addresses from the Interface.
+func (i Interface) SetDisableIPv6(disable bool) error {
+	return i.writeSysctlBool("ipv6", "disable_ipv6", disable)
+}
+
+// DisableIPv6 will return true if IPv6 is disabled on the Interface.
+func (i Interface) DisableIPv6() (bool, error) {
+	return i.readSysctlBool("ipv6", "disable_ipv6")
+}
+
+// SetAutoconf will enable or disable IPv6 address autoconfiguration
+// (SLAAC) from Router Advertisements on the Interface.
+func (i Interface) SetAutoconf(autoconf bool) error {
+	return i.writeSysctlBool("ipv6", "autoconf", autoconf)
+}
+
+// Autoconf will return true if IPv6 address autoconfiguration is enabled on
+// the Interface.
+func (i Interface) Autoconf() (bool, error) {
+	return i.readSysctlBool("ipv6", "autoconf")
+}
+
+// sysctlPath will return the path to the per-interface sysctl.
+func (i Interface) sysctlPath(family, key string) string {
+	return filepath.Join("/proc/sys/net", family, "conf", i.Name(), key)
+}
+
+// readSysctl will read the per-interface sysctl. Since /proc/sys/net is per
+// network namespace, this is done from the namespace the Interface was
+// created in.
+func (i Interface) readSysctl(family, key string) (string, error) {
+	var buf []byte
+	err := i.ps.inNetns(func() error {
+		var err error
+		buf, err = os.ReadFile(i.sysctlPath(family, key))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// writeSysctl will write the per-interface sysctl, from the network
+// namespace the Interface was created in.
+func (i Interface) writeSysctl(family, key, value string) error {
+	return i.ps.inNetns(func() error {
+		return os.WriteFile(i.sysctlPath(family, key), []byte(value), 0)
+	})
+}
+
+func (i Interface) readSysctlUint(family, key string) (uint64, error) {
+	v, err := i.readSysctl(family, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("tap: invalid value for %s: %q", key, v)
+	}
+	return n, nil
+}
+
+func (i Interface) writeSysctlUint(family, key string, value uint64) error {
+	return i.writeSysctl(family, key, strconv.FormatUint(value, 10))
+}
+
+func (i Interface) readSysctlBool(family, key string) (bool, error) {
+	v, err := i.readSysctlUint(family, key)
+	return v != 0, err
+}
+
+func (i Interface) writeSysctlBool(family, key string, value bool) error {
+	if value {
+		return i.writeSysctl(family, key, "1")
+	}
+	return i.writeSysctl(family, key, "0")
+}
+
+// vim: foldmethod=marker
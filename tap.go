@@ -22,8 +22,13 @@ package tap
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/mdlayher/ethernet"
 	"golang.org/x/sys/unix"
@@ -31,22 +36,52 @@ import (
 
 // Interface is a handle to a created TAP/TUN interface.
 type Interface struct {
-	name   [syscall.IFNAMSIZ]byte
+	// name is shared by every copy of this Interface, so that it can be
+	// replaced by Rename.
+	name   *atomic.Pointer[[syscall.IFNAMSIZ]byte]
 	fd     *os.File
 	ctx    context.Context
 	cancel context.CancelFunc
 	ps     platformState
+
+	announce AnnounceOptions
+	metrics  *ioMetrics
+	closer   *closer
+}
+
+// closer will release the resources held by an Interface exactly once, no
+// matter how many times (or from where) close is called.
+type closer struct {
+	once sync.Once
+	fn   func() error
+	err  error
+}
+
+func (c *closer) close() error {
+	c.once.Do(func() {
+		c.err = c.fn()
+	})
+	return c.err
 }
 
 // Name will return the UNIX interface name for the TAP/TUN interface.
 func (i Interface) Name() string {
-	return unix.ByteSliceToString(i.name[:])
+	name := i.ifname()
+	return unix.ByteSliceToString(name[:])
 }
 
-// Close will release all resources held by this Interface.
+// ifname will return the UNIX interface name as the fixed size array used
+// by ifreq structs.
+func (i Interface) ifname() [syscall.IFNAMSIZ]byte {
+	return *i.name.Load()
+}
+
+// Close will release all resources held by this Interface. Anything the
+// Interface has added to the system (such as policy routing rules) will
+// have been removed by the time Close returns.
 func (i Interface) Close() error {
 	i.cancel()
-	return nil
+	return i.closer.close()
 }
 
 // Options contains a number of configuration params for the creation of
@@ -54,6 +89,10 @@ func (i Interface) Close() error {
 // may contain options on your OS that are not present on other OSs.
 type Options struct {
 	PlatformOptions PlatformOptions
+
+	// Announce controls how the Interface will announce its addresses
+	// with Announce, and if it will do so when they change.
+	Announce AnnounceOptions
 }
 
 // New will create a new TAP interface.
@@ -62,29 +101,75 @@ func New(ctx context.Context, o Options) (*Interface, error) {
 
 	name, fd, ps, err := requestInterface(o)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
+	closer := &closer{fn: func() error {
+		// Closing the fd will destroy the TAP (and any links on top of
+		// it), so the platform state has to be cleaned up first, while
+		// the things it tracks still exist.
+		err := ps.Close()
+		if fdErr := fd.Close(); err == nil {
+			err = fdErr
+		}
+		return err
+	}}
+
 	go func() {
 		// Here, we'll sit in a goroutine and close the underlying fd when the
 		// context is cancelled. This way, all code using the interface can
 		// depend on the Context for state, and not worry about a cancelled
 		// context without freeing the interface.
 		<-ctx.Done()
-		fd.Close()
-		ps.Close()
+		closer.close()
 	}()
 
-	return &Interface{
+	iface := &Interface{
 		ctx:    ctx,
 		cancel: cancel,
-		name:   name,
+		name:   &atomic.Pointer[[syscall.IFNAMSIZ]byte]{},
 		fd:     fd,
 		ps:     ps,
-	}, nil
+
+		announce: o.Announce,
+		metrics:  newIOMetrics(),
+		closer:   closer,
+	}
+	iface.name.Store(&name)
+
+	if err := iface.startPlatform(o); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return iface, nil
+}
+
+// UnmarshalError is returned by Read when a frame was read from the TAP, but
+// could not be parsed as an Ethernet Frame. Unlike other errors from Read,
+// the Interface is still usable, and the next call to Read will return the
+// next frame.
+type UnmarshalError struct {
+	Err error
+}
+
+// Error will return the error message of the underlying error.
+func (e UnmarshalError) Error() string {
+	return fmt.Sprintf("tap: can't unmarshal frame: %s", e.Err)
+}
+
+// Unwrap will return the underlying error from ethernet.Frame.
+func (e UnmarshalError) Unwrap() error {
+	return e.Err
 }
 
 // Read will read and parse the next Ethernet Frame from the TAP.
+//
+// If a frame was read from the TAP but could not be parsed, Read will return
+// an UnmarshalError (which wraps the error from ethernet.Frame), and the
+// frame is dropped. Callers can check for it with errors.As and keep
+// reading; any other error means the Interface is no longer usable.
 func (i Interface) Read() (*ethernet.Frame, error) {
 	var (
 		frame = &ethernet.Frame{}
@@ -98,20 +183,54 @@ func (i Interface) Read() (*ethernet.Frame, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Time spent blocked waiting for a frame says more about the peer than
+	// about us, so only time what we do once we have one.
+	start := time.Now()
 	if err := frame.UnmarshalBinary(buf[:n]); err != nil {
-		return nil, err
+		i.metrics.unmarshalErrors.Add(1)
+		return nil, UnmarshalError{Err: err}
 	}
+	i.metrics.observeRead(time.Since(start))
+	i.metrics.framesRead.Add(1)
+	i.metrics.bytesRead.Add(uint64(n))
 	return frame, nil
 }
 
+// FrameReadWriter is the frame level API of an Interface. Types in this
+// package that process frames on a TAP will both consume and implement this
+// interface, so that they can be stacked on top of each other (or any other
+// type that reads and writes Ethernet Frames).
+type FrameReadWriter interface {
+	// Read will return the next Ethernet Frame.
+	Read() (*ethernet.Frame, error)
+
+	// Write will send the Ethernet Frame.
+	Write(*ethernet.Frame) error
+}
+
 // Write will encode the passed ethernet.Frame to the TAP.
 func (i Interface) Write(frame *ethernet.Frame) error {
 	buf, err := frame.MarshalBinary()
 	if err != nil {
 		return err
 	}
-	_, err = i.fd.Write(buf)
-	return err
+	n, err := i.fd.Write(buf)
+	if n > 0 {
+		i.metrics.bytesWritten.Add(uint64(n))
+		if n < len(buf) {
+			// os.File will return an error along with a short write, but
+			// we still want to count it.
+			i.metrics.shortWrites.Add(1)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if n < len(buf) {
+		return io.ErrShortWrite
+	}
+	i.metrics.framesWritten.Add(1)
+	return nil
 }
 
 func ioctl(action uintptr, fd uintptr, arg uintptr) error {
@@ -25,10 +25,13 @@ package tap
 import (
 	"fmt"
 	"os"
+	"runtime"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
 	"golang.org/x/sys/unix"
 )
 
@@ -40,6 +43,10 @@ type PlatformOptions struct {
 	// of a tap* name (like tap0, tap5, tap3)
 	Name string
 
+	// CancelOnDelete will cancel the Interface's Context (as if Close was
+	// called) if the TAP interface is deleted by another process.
+	CancelOnDelete bool
+
 	// Owner string
 	// Group string
 }
@@ -58,13 +65,102 @@ type ifReqFlags struct {
 }
 
 type platformState struct {
-	netif netlink.Link
+	// netif is the netlink.Link of the TAP, which is shared by every copy
+	// of the Interface, and replaced by Refresh.
+	netif *atomic.Pointer[netlink.Link]
+
+	// netns is the network namespace the TAP was created in, which is
+	// the namespace of the calling thread at the time New was called.
+	netns netns.NsHandle
+
+	// rules are the policy routing rules created by this Interface, which
+	// need to be removed when the Interface is closed.
+	rules *ruleTracker
+
+	// links are the child links (such as VLANs) created on top of this
+	// Interface, which need to be removed when the Interface is closed.
+	links *linkTracker
 }
 
 func (ps platformState) Close() error {
+	defer ps.netns.Close()
+	linkErr := ps.links.close()
+	if err := ps.rules.close(); err != nil {
+		return err
+	}
+	return linkErr
+}
+
+// rename will update the platform state after the Interface has been
+// renamed.
+func (ps platformState) rename(name string) error {
+	return ps.rules.rename(name)
+}
+
+// startPlatform will start any Linux specific background work for the
+// Interface.
+func (i Interface) startPlatform(opts Options) error {
+	if !opts.PlatformOptions.CancelOnDelete {
+		return nil
+	}
+
+	events, err := i.WatchLink(i.ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for ev := range events {
+			if ev.Type == LinkEventDelete {
+				i.cancel()
+			}
+		}
+	}()
 	return nil
 }
 
+// inNetns will call fn on a thread in the network namespace the TAP was
+// created in, for things (like /proc/sys/net) which depend on the namespace
+// of the calling thread, rather than the interface index.
+func (ps platformState) inNetns(fn func() error) error {
+	runtime.LockOSThread()
+
+	orig, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer orig.Close()
+
+	if orig.Equal(ps.netns) {
+		runtime.UnlockOSThread()
+		return fn()
+	}
+
+	if err := netns.Set(ps.netns); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	err = fn()
+	if nsErr := netns.Set(orig); nsErr != nil {
+		// We can't put this thread back, so leave it locked to this
+		// goroutine rather than let anything else run in the wrong
+		// namespace.
+		return nsErr
+	}
+	runtime.UnlockOSThread()
+	return err
+}
+
+// link will return the cached netlink.Link of the TAP.
+func (ps platformState) link() netlink.Link {
+	return *ps.netif.Load()
+}
+
+// index will return the kernel interface index of the TAP.
+func (ps platformState) index() int {
+	return ps.link().Attrs().Index
+}
+
 // requestInterface will open the TAP/TUN kernel interface, request a new
 // TAP/TUN device, and return the interface's name.
 //
@@ -120,9 +216,19 @@ func requestInterface(opts Options) ([syscall.IFNAMSIZ]byte, *os.File, platformS
 		return req.Name, nil, platformState{}, err
 	}
 
+	ns, err := netns.Get()
+	if err != nil {
+		file.Close()
+		return req.Name, nil, platformState{}, err
+	}
+
 	ps := platformState{
-		netif: netif,
+		netif: &atomic.Pointer[netlink.Link]{},
+		netns: ns,
+		rules: &ruleTracker{},
+		links: &linkTracker{},
 	}
+	ps.netif.Store(&netif)
 
 	// TODO(paultag): Allow for TTUNPERSIST/UNSETOWNER/TUNSETGROUP here.
 	return req.Name, file, ps, nil
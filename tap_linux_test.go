@@ -0,0 +1,143 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netns"
+)
+
+// newTestInterface will create a TAP for the test, or skip the test if this
+// process isn't allowed to create one.
+func newTestInterface(t *testing.T, opts Options) *Interface {
+	t.Helper()
+	iface, err := New(context.Background(), opts)
+	if err != nil {
+		t.Skipf("can't create a TAP: %s", err)
+	}
+	t.Cleanup(func() { iface.Close() })
+	return iface
+}
+
+// newTestInterfaceInNetns will create a TAP for the test in a new network
+// namespace, leaving the calling goroutine in its original namespace, or
+// skip the test if this process isn't allowed to.
+func newTestInterfaceInNetns(t *testing.T, opts Options) *Interface {
+	t.Helper()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+
+	ns, err := netns.New()
+	if err != nil {
+		t.Skipf("can't create a network namespace: %s", err)
+	}
+	defer ns.Close()
+
+	iface, newErr := New(context.Background(), opts)
+	if err := netns.Set(orig); err != nil {
+		t.Fatal(err)
+	}
+	if newErr != nil {
+		t.Skipf("can't create a TAP: %s", newErr)
+	}
+	t.Cleanup(func() { iface.Close() })
+	return iface
+}
+
+func TestRefreshWhileAnnouncing(t *testing.T) {
+	iface := newTestInterface(t, Options{
+		Announce: AnnounceOptions{Count: 20, Interval: time.Millisecond},
+	})
+	if err := iface.SetUp(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := iface.Announce(net.IPv4(10, 78, 0, 1)); err != nil {
+		t.Skipf("can't transmit on the TAP: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			if err := iface.Refresh(); err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestRenameWhileReadingName(t *testing.T) {
+	iface := newTestInterface(t, Options{})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			iface.Name()
+			iface.ps.index()
+		}
+	}()
+
+	err := iface.Rename("taprename0")
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := iface.Name(); name != "taprename0" {
+		t.Fatalf("Name() = %q after Rename", name)
+	}
+	state, err := iface.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Name != "taprename0" {
+		t.Fatalf("kernel name is %q after Rename", state.Name)
+	}
+}
+
+// vim: foldmethod=marker
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 )
@@ -34,13 +35,37 @@ type PlatformOptions struct {
 }
 
 type platformState struct {
-	netif *net.Interface
+	// netif is the net.Interface of the TAP, which is shared by every copy
+	// of the Interface, and replaced by Refresh.
+	netif *atomic.Pointer[net.Interface]
 }
 
 func (ps platformState) Close() error {
 	return nil
 }
 
+// startPlatform will start any OpenBSD specific background work for the
+// Interface. There isn't any yet.
+func (i Interface) startPlatform(opts Options) error {
+	return nil
+}
+
+// rename will update the platform state after the Interface has been
+// renamed. There's nothing on OpenBSD that depends on the name.
+func (ps platformState) rename(name string) error {
+	return nil
+}
+
+// link will return the cached net.Interface of the TAP.
+func (ps platformState) link() *net.Interface {
+	return ps.netif.Load()
+}
+
+// index will return the kernel interface index of the TAP.
+func (ps platformState) index() int {
+	return ps.link().Index
+}
+
 // tuninfo is used by the TUNSIFINFO ioctl to set the TUN state.
 type tuninfo struct {
 	MTU   uint32
@@ -106,8 +131,9 @@ func requestInterface(opts Options) ([syscall.IFNAMSIZ]byte, *os.File, platformS
 		return name, nil, platformState{}, err
 	}
 	ps := platformState{
-		netif: netif,
+		netif: &atomic.Pointer[net.Interface]{},
 	}
+	ps.netif.Store(netif)
 	return name, fd, ps, nil
 }
 
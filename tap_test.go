@@ -0,0 +1,68 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"io"
+	"sync"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// testFrames is a FrameReadWriter which will return each of the Frames it
+// was created with from Read, and then io.EOF, recording every Frame that
+// is written to it.
+type testFrames struct {
+	mu      sync.Mutex
+	reads   []*ethernet.Frame
+	written []*ethernet.Frame
+}
+
+func newTestFrames(reads ...*ethernet.Frame) *testFrames {
+	return &testFrames{reads: reads}
+}
+
+func (f *testFrames) Read() (*ethernet.Frame, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.reads) == 0 {
+		return nil, io.EOF
+	}
+	frame := f.reads[0]
+	f.reads = f.reads[1:]
+	return frame, nil
+}
+
+func (f *testFrames) Write(frame *ethernet.Frame) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.written = append(f.written, frame)
+	return nil
+}
+
+// Written will return every Frame written so far.
+func (f *testFrames) Written() []*ethernet.Frame {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*ethernet.Frame{}, f.written...)
+}
+
+// vim: foldmethod=marker
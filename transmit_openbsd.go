@@ -0,0 +1,67 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build openbsd
+
+package tap
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ifreqBPF is the ifreq used to bind a bpf(4) device to an interface; only
+// the name is used, but the kernel will copy the entire struct.
+type ifreqBPF struct {
+	Name [syscall.IFNAMSIZ]byte
+	_    [16]byte
+}
+
+// transmit will send the raw Ethernet frame out of the TAP from the host
+// side, using a bpf(4) device bound to the interface. The frame will be
+// delivered to the reader of the TAP, and anything else listening on the
+// interface.
+func (i Interface) transmit(frame []byte) error {
+	file, err := os.OpenFile("/dev/bpf", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	req := ifreqBPF{Name: i.ifname()}
+	if err := ioctl(unix.BIOCSETIF, file.Fd(), uintptr(unsafe.Pointer(&req))); err != nil {
+		return err
+	}
+
+	// We've built the whole frame, including the source hardware address,
+	// so tell bpf(4) not to fill it in for us.
+	hdrcmplt := uint32(1)
+	if err := ioctl(unix.BIOCSHDRCMPLT, file.Fd(), uintptr(unsafe.Pointer(&hdrcmplt))); err != nil {
+		return err
+	}
+
+	_, err = file.Write(frame)
+	return err
+}
+
+// vim: foldmethod=marker
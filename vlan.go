@@ -0,0 +1,284 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// VLANSplitterOptions contains configuration for a VLANSplitter.
+type VLANSplitterOptions struct {
+	// ServiceVLAN will split Frames on their 802.1ad S-VLAN tag, rather
+	// than their 802.1Q C-VLAN tag. Frames read from a VLANHandle will
+	// still have their C-VLAN tag, so another VLANSplitter can be stacked
+	// on top of the VLANHandle to split on that.
+	//
+	// An S-VLAN tag can't be sent without a C-VLAN tag inside it, so
+	// Frames written to a VLANHandle (other than the native VLAN) must
+	// have a C-VLAN tag. This means the Native VLANHandle of a
+	// VLANSplitter stacked on top of a VLANHandle can't be written to.
+	ServiceVLAN bool
+
+	// QueueLength is the number of Frames that may be waiting to be read
+	// from each VLANHandle before new Frames for that VLAN are dropped. If
+	// unset, this defaults to 128.
+	QueueLength int
+}
+
+// VLANSplitter will read Frames from a FrameReadWriter, and split them into
+// a VLANHandle per VLAN ID. Each VLANHandle is a FrameReadWriter which strips
+// the VLAN tag from Frames on Read, and adds the VLAN tag to Frames on Write,
+// so that each VLAN can be treated as if it were a separate Interface.
+//
+// Once created, the VLANSplitter is the only reader of the FrameReadWriter.
+// Frames for VLANs without a VLANHandle are dropped.
+type VLANSplitter struct {
+	frames FrameReadWriter
+	opts   VLANSplitterOptions
+
+	mu      sync.Mutex
+	handles map[uint16]*VLANHandle
+	err     error
+}
+
+// VLANHandle is the FrameReadWriter for a single VLAN on a VLANSplitter.
+type VLANHandle struct {
+	splitter *VLANSplitter
+	id       uint16
+	ch       chan *ethernet.Frame
+}
+
+// NewVLANSplitter will create a VLANSplitter, and start reading Frames from
+// the provided FrameReadWriter, which is usually an Interface. Frames will
+// be read until the FrameReadWriter returns an error, which will then be
+// returned from every VLANHandle. An UnmarshalError (a single bad frame) will
+// not stop the VLANSplitter.
+func NewVLANSplitter(frames FrameReadWriter, opts VLANSplitterOptions) *VLANSplitter {
+	if opts.QueueLength <= 0 {
+		opts.QueueLength = 128
+	}
+	s := &VLANSplitter{
+		frames:  frames,
+		opts:    opts,
+		handles: map[uint16]*VLANHandle{},
+	}
+	go s.run()
+	return s
+}
+
+// VLAN will return a VLANHandle for Frames tagged with the VLAN ID. The
+// native (untagged) VLAN is VLAN ID 0, which is also returned by Native.
+func (s *VLANSplitter) VLAN(id uint16) (*VLANHandle, error) {
+	if id >= ethernet.VLANMax {
+		return nil, fmt.Errorf("tap: invalid VLAN ID: %d", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	if _, ok := s.handles[id]; ok {
+		return nil, fmt.Errorf("tap: VLAN %d already has a handle", id)
+	}
+
+	h := &VLANHandle{
+		splitter: s,
+		id:       id,
+		ch:       make(chan *ethernet.Frame, s.opts.QueueLength),
+	}
+	s.handles[id] = h
+	return h, nil
+}
+
+// Native will return a VLANHandle for untagged Frames.
+func (s *VLANSplitter) Native() (*VLANHandle, error) {
+	return s.VLAN(ethernet.VLANNone)
+}
+
+// run will read Frames from the underlying FrameReadWriter, and send them
+// to the VLANHandle for their VLAN, until the read fails.
+func (s *VLANSplitter) run() {
+	for {
+		frame, err := s.frames.Read()
+		if err != nil {
+			if errors.As(err, &UnmarshalError{}) {
+				// The peer sent us something we can't parse; that's no
+				// reason to take down every VLAN.
+				continue
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.err = err
+			for _, h := range s.handles {
+				close(h.ch)
+			}
+			s.handles = map[uint16]*VLANHandle{}
+			return
+		}
+
+		id, ok := s.untag(frame)
+		if !ok {
+			continue
+		}
+
+		s.mu.Lock()
+		if h, ok := s.handles[id]; ok {
+			select {
+			case h.ch <- frame:
+			default:
+				// The reader is behind; drop the Frame, like a NIC would.
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// untag will remove the outer VLAN tag this VLANSplitter splits on from
+// the Frame, returning the VLAN ID it was tagged with.
+func (s *VLANSplitter) untag(frame *ethernet.Frame) (uint16, bool) {
+	if s.opts.ServiceVLAN {
+		if frame.ServiceVLAN == nil {
+			return ethernet.VLANNone, true
+		}
+		id := frame.ServiceVLAN.ID
+		frame.ServiceVLAN = nil
+		return id, true
+	}
+
+	if frame.ServiceVLAN != nil {
+		// This has an S-VLAN tag outside the C-VLAN tag, which
+		// isn't ours to deal with.
+		return 0, false
+	}
+	if frame.VLAN == nil {
+		return ethernet.VLANNone, true
+	}
+
+	id := frame.VLAN.ID
+	frame.VLAN = nil
+
+	// If this was a stacked 802.1Q Frame, pull the inner tag out of the
+	// payload, so that the next VLANSplitter can see it.
+	if frame.EtherType == ethernet.EtherTypeVLAN {
+		if len(frame.Payload) < 4 {
+			return 0, false
+		}
+		inner := &ethernet.VLAN{}
+		if err := inner.UnmarshalBinary(frame.Payload[:2]); err != nil {
+			return 0, false
+		}
+		frame.VLAN = inner
+		frame.EtherType = ethernet.EtherType(binary.BigEndian.Uint16(frame.Payload[2:4]))
+		frame.Payload = frame.Payload[4:]
+	}
+	return id, true
+}
+
+// tag will return a copy of the Frame with the VLAN tag for the ID added.
+func (s *VLANSplitter) tag(id uint16, frame *ethernet.Frame) (*ethernet.Frame, error) {
+	f := *frame
+	if id == ethernet.VLANNone {
+		return &f, nil
+	}
+
+	tag := &ethernet.VLAN{ID: id}
+	if s.opts.ServiceVLAN {
+		if f.VLAN == nil {
+			return nil, fmt.Errorf("tap: can't send an S-VLAN tag without a C-VLAN tag")
+		}
+		f.ServiceVLAN = tag
+		return &f, nil
+	}
+
+	if f.ServiceVLAN != nil {
+		return nil, fmt.Errorf("tap: can't add a C-VLAN tag outside an S-VLAN tag")
+	}
+
+	// If there's already a tag, this is a stacked 802.1Q Frame, so push
+	// the existing tag into the payload.
+	if f.VLAN != nil {
+		inner, err := f.VLAN.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		payload := make([]byte, 4+len(f.Payload))
+		copy(payload[0:2], inner)
+		binary.BigEndian.PutUint16(payload[2:4], uint16(f.EtherType))
+		copy(payload[4:], f.Payload)
+		f.Payload = payload
+		f.EtherType = ethernet.EtherTypeVLAN
+	}
+	f.VLAN = tag
+	return &f, nil
+}
+
+// ID will return the VLAN ID of this VLANHandle, which is 0 for the native
+// VLAN.
+func (h *VLANHandle) ID() uint16 {
+	return h.id
+}
+
+// Read will return the next Frame in this VLAN, with the VLAN tag removed.
+func (h *VLANHandle) Read() (*ethernet.Frame, error) {
+	frame, ok := <-h.ch
+	if !ok {
+		h.splitter.mu.Lock()
+		defer h.splitter.mu.Unlock()
+		if h.splitter.err != nil {
+			return nil, h.splitter.err
+		}
+		return nil, io.EOF
+	}
+	return frame, nil
+}
+
+// Write will add the VLAN tag to the Frame, and write it to the underlying
+// FrameReadWriter. The passed Frame is not modified.
+func (h *VLANHandle) Write(frame *ethernet.Frame) error {
+	f, err := h.splitter.tag(h.id, frame)
+	if err != nil {
+		return err
+	}
+	return h.splitter.frames.Write(f)
+}
+
+// Close will remove this VLANHandle from the VLANSplitter. Frames in this
+// VLAN will be dropped until a new VLANHandle is created.
+func (h *VLANHandle) Close() error {
+	s := h.splitter
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handles[h.id] == h {
+		delete(s.handles, h.id)
+		close(h.ch)
+	}
+	return nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,179 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// linkTracker keeps track of every child link created on an Interface.
+type linkTracker struct {
+	mu    sync.Mutex
+	links []netlink.Link
+}
+
+func (lt *linkTracker) add(link netlink.Link) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.links = append(lt.links, link)
+}
+
+func (lt *linkTracker) remove(link netlink.Link) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	for j, l := range lt.links {
+		if l.Attrs().Index == link.Attrs().Index {
+			lt.links = append(lt.links[:j], lt.links[j+1:]...)
+			return
+		}
+	}
+}
+
+// close will delete every tracked link, returning the first error
+// encountered. This has to be called before the TAP is destroyed; the kernel
+// would delete the links along with the TAP anyway, but we'd like them to be
+// gone by the time Close returns. Links that are already gone (for instance,
+// if the TAP was deleted by another process) are not an error.
+func (lt *linkTracker) close() error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	var ret error
+	for _, link := range lt.links {
+		err := netlink.LinkDel(link)
+		if errors.Is(err, syscall.ENODEV) {
+			continue
+		}
+		if err != nil && ret == nil {
+			ret = err
+		}
+	}
+	lt.links = nil
+	return ret
+}
+
+// VLANLinkOptions contains configuration for a kernel VLAN link created with
+// AddVLANLink.
+type VLANLinkOptions struct {
+	// Name is the name of the VLAN link. If unset, this defaults to the
+	// name of the Interface, a dot, and the VLAN ID (like tap0.100).
+	Name string
+
+	// ServiceVLAN will create an 802.1ad (S-VLAN) link rather than an
+	// 802.1Q (C-VLAN) link.
+	ServiceVLAN bool
+}
+
+// VLANLink is a handle to a kernel VLAN link created on top of an Interface.
+// VLANLinks are deleted when the Interface is closed.
+type VLANLink struct {
+	link  netlink.Link
+	links *linkTracker
+}
+
+// AddVLANLink will create a kernel VLAN link on top of the Interface.
+func (i Interface) AddVLANLink(id uint16, opts VLANLinkOptions) (*VLANLink, error) {
+	if id == 0 || id >= 0xfff {
+		return nil, fmt.Errorf("tap: invalid VLAN ID: %d", id)
+	}
+
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = opts.Name
+	if attrs.Name == "" {
+		attrs.Name = fmt.Sprintf("%s.%d", i.Name(), id)
+	}
+	if len(attrs.Name) >= syscall.IFNAMSIZ {
+		return nil, fmt.Errorf("tap: VLAN link name %q is too long", attrs.Name)
+	}
+	attrs.ParentIndex = i.ps.index()
+
+	proto := netlink.VLAN_PROTOCOL_8021Q
+	if opts.ServiceVLAN {
+		proto = netlink.VLAN_PROTOCOL_8021AD
+	}
+
+	link := &netlink.Vlan{
+		LinkAttrs:    attrs,
+		VlanId:       int(id),
+		VlanProtocol: proto,
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return nil, err
+	}
+
+	// Fetch the link back, so that we know the index the kernel assigned.
+	created, err := netlink.LinkByName(attrs.Name)
+	if err != nil {
+		netlink.LinkDel(link)
+		return nil, err
+	}
+
+	i.ps.links.add(created)
+	return &VLANLink{link: created, links: i.ps.links}, nil
+}
+
+// Name will return the interface name of the VLAN link.
+func (v VLANLink) Name() string {
+	return v.link.Attrs().Name
+}
+
+// Close will delete the VLAN link.
+func (v VLANLink) Close() error {
+	v.links.remove(v.link)
+	return netlink.LinkDel(v.link)
+}
+
+// SetHardwareAddr will set the hardware address of the VLAN link.
+func (v VLANLink) SetHardwareAddr(addr net.HardwareAddr) error {
+	return netlink.LinkSetHardwareAddr(v.link, addr)
+}
+
+// SetUp will set the VLAN link up or down.
+func (v VLANLink) SetUp(updown bool) error {
+	if updown {
+		return netlink.LinkSetUp(v.link)
+	}
+	return netlink.LinkSetDown(v.link)
+}
+
+// SetMTU will set the MTU of the VLAN link.
+func (v VLANLink) SetMTU(sz uint) error {
+	return netlink.LinkSetMTU(v.link, int(sz))
+}
+
+// AddAddr will add the IP address in the provided network to the VLAN link.
+func (v VLANLink) AddAddr(ip net.IP, network *net.IPNet) error {
+	network.IP = ip
+	return netlink.AddrAdd(v.link, &netlink.Addr{
+		IPNet: network,
+		Peer:  network,
+	})
+}
+
+// vim: foldmethod=marker
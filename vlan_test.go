@@ -0,0 +1,310 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+package tap
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// chanFrames is a FrameReadWriter which will block in Read until a Frame or
+// an error is sent, so that a VLANSplitter doesn't read anything before the
+// test has created its VLANHandles.
+type chanFrames struct {
+	reads chan chanRead
+	*testFrames
+}
+
+type chanRead struct {
+	frame *ethernet.Frame
+	err   error
+}
+
+func newChanFrames() *chanFrames {
+	return &chanFrames{
+		reads:      make(chan chanRead),
+		testFrames: newTestFrames(),
+	}
+}
+
+func (f *chanFrames) Read() (*ethernet.Frame, error) {
+	read := <-f.reads
+	return read.frame, read.err
+}
+
+func testFrame(vlans ...uint16) *ethernet.Frame {
+	frame := &ethernet.Frame{
+		Destination: testMAC,
+		Source:      testPeerMAC,
+		EtherType:   ethernet.EtherTypeIPv4,
+		Payload:     []byte{0x45, 0x00, 0x00, 0x14},
+	}
+	for _, id := range vlans {
+		var err error
+		frame, err = (&VLANSplitter{}).tag(id, frame)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return frame
+}
+
+func TestVLANTag(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		service bool
+		id      uint16
+		frame   *ethernet.Frame
+		want    *ethernet.Frame
+		err     bool
+	}{
+		{
+			name:  "native",
+			frame: testFrame(),
+			want:  testFrame(),
+		},
+		{
+			name:  "c-vlan",
+			id:    100,
+			frame: testFrame(),
+			want: &ethernet.Frame{
+				Destination: testMAC,
+				Source:      testPeerMAC,
+				VLAN:        &ethernet.VLAN{ID: 100},
+				EtherType:   ethernet.EtherTypeIPv4,
+				Payload:     []byte{0x45, 0x00, 0x00, 0x14},
+			},
+		},
+		{
+			name:  "stacked c-vlan",
+			id:    100,
+			frame: testFrame(200),
+			want: &ethernet.Frame{
+				Destination: testMAC,
+				Source:      testPeerMAC,
+				VLAN:        &ethernet.VLAN{ID: 100},
+				EtherType:   ethernet.EtherTypeVLAN,
+				Payload:     []byte{0x00, 0xc8, 0x08, 0x00, 0x45, 0x00, 0x00, 0x14},
+			},
+		},
+		{
+			name:    "s-vlan",
+			service: true,
+			id:      300,
+			frame:   testFrame(100),
+			want: &ethernet.Frame{
+				Destination: testMAC,
+				Source:      testPeerMAC,
+				ServiceVLAN: &ethernet.VLAN{ID: 300},
+				VLAN:        &ethernet.VLAN{ID: 100},
+				EtherType:   ethernet.EtherTypeIPv4,
+				Payload:     []byte{0x45, 0x00, 0x00, 0x14},
+			},
+		},
+		{
+			name:    "s-vlan without c-vlan",
+			service: true,
+			id:      300,
+			frame:   testFrame(),
+			err:     true,
+		},
+		{
+			name: "c-vlan outside s-vlan",
+			id:   100,
+			frame: &ethernet.Frame{
+				ServiceVLAN: &ethernet.VLAN{ID: 300},
+				VLAN:        &ethernet.VLAN{ID: 200},
+				EtherType:   ethernet.EtherTypeIPv4,
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &VLANSplitter{opts: VLANSplitterOptions{ServiceVLAN: tc.service}}
+			orig := *tc.frame
+			got, err := s.tag(tc.id, tc.frame)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			if !reflect.DeepEqual(*tc.frame, orig) {
+				t.Fatal("tag modified the passed Frame")
+			}
+
+			// Untagging must give us back what we started with.
+			id, ok := s.untag(got)
+			if !ok || id != tc.id {
+				t.Fatalf("untag = %d, %t; want %d", id, ok, tc.id)
+			}
+			if !reflect.DeepEqual(got, tc.frame) {
+				t.Fatalf("untagged %+v, want %+v", got, tc.frame)
+			}
+		})
+	}
+}
+
+func TestVLANUntagDrops(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		frame *ethernet.Frame
+	}{
+		{"s-vlan on a c-vlan splitter", &ethernet.Frame{
+			ServiceVLAN: &ethernet.VLAN{ID: 300},
+			VLAN:        &ethernet.VLAN{ID: 100},
+			EtherType:   ethernet.EtherTypeIPv4,
+		}},
+		{"truncated stacked tag", &ethernet.Frame{
+			VLAN:      &ethernet.VLAN{ID: 100},
+			EtherType: ethernet.EtherTypeVLAN,
+			Payload:   []byte{0x00},
+		}},
+	} {
+		if _, ok := (&VLANSplitter{}).untag(tc.frame); ok {
+			t.Errorf("%s: frame wasn't dropped", tc.name)
+		}
+	}
+}
+
+func TestVLANSplitter(t *testing.T) {
+	frames := newChanFrames()
+	s := NewVLANSplitter(frames, VLANSplitterOptions{})
+
+	native, err := s.Native()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v100, err := s.VLAN(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VLAN(100); err == nil {
+		t.Fatal("created a second handle for VLAN 100")
+	}
+	if _, err := s.VLAN(ethernet.VLANMax); err == nil {
+		t.Fatal("created a handle for an invalid VLAN")
+	}
+
+	for _, read := range []chanRead{
+		{frame: testFrame(200)},
+		{err: UnmarshalError{Err: io.ErrUnexpectedEOF}},
+		{frame: testFrame(100)},
+		{frame: testFrame()},
+	} {
+		frames.reads <- read
+	}
+
+	for _, tc := range []struct {
+		handle *VLANHandle
+		want   *ethernet.Frame
+	}{
+		{v100, testFrame()},
+		{native, testFrame()},
+	} {
+		got, err := tc.handle.Read()
+		if err != nil {
+			t.Fatalf("VLAN %d: %s", tc.handle.ID(), err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("VLAN %d: got %+v, want %+v", tc.handle.ID(), got, tc.want)
+		}
+	}
+
+	if err := v100.Write(testFrame()); err != nil {
+		t.Fatal(err)
+	}
+	written := frames.Written()
+	if len(written) != 1 || !reflect.DeepEqual(written[0], testFrame(100)) {
+		t.Fatalf("wrote %+v, want a Frame tagged with VLAN 100", written)
+	}
+
+	readErr := errors.New("tap: test read error")
+	frames.reads <- chanRead{err: readErr}
+	for _, h := range []*VLANHandle{native, v100} {
+		if _, err := h.Read(); err != readErr {
+			t.Fatalf("VLAN %d: Read() = %v, want %v", h.ID(), err, readErr)
+		}
+	}
+	if _, err := s.VLAN(10); err != readErr {
+		t.Fatalf("VLAN() after the read failed = %v, want %v", err, readErr)
+	}
+}
+
+func TestVLANSplitterStacked(t *testing.T) {
+	frames := newChanFrames()
+	outer := NewVLANSplitter(frames, VLANSplitterOptions{ServiceVLAN: true})
+	svlan, err := outer.VLAN(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := NewVLANSplitter(svlan, VLANSplitterOptions{})
+	cvlan, err := inner.VLAN(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qinq := testFrame(100)
+	qinq.ServiceVLAN = &ethernet.VLAN{ID: 300}
+	frames.reads <- chanRead{frame: qinq}
+
+	got, err := cvlan.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, testFrame()) {
+		t.Fatalf("got %+v, want an untagged Frame", got)
+	}
+
+	if err := cvlan.Write(testFrame()); err != nil {
+		t.Fatal(err)
+	}
+	written := frames.Written()
+	want := testFrame(100)
+	want.ServiceVLAN = &ethernet.VLAN{ID: 300}
+	if len(written) != 1 || !reflect.DeepEqual(written[0], want) {
+		t.Fatalf("wrote %+v, want %+v", written, want)
+	}
+
+	// The native VLAN of the inner splitter has no C-VLAN tag, so there's
+	// nothing to put the S-VLAN tag on.
+	native, err := inner.Native()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := native.Write(testFrame()); err == nil {
+		t.Fatal("wrote an S-VLAN tagged Frame without a C-VLAN tag")
+	}
+
+	frames.reads <- chanRead{err: io.EOF}
+}
+
+// vim: foldmethod=marker
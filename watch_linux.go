@@ -0,0 +1,47 @@
+// {{{ Copyright (c) Paul R. Tagliamonte <paul@k3xec.com>, 2022
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE. }}}
+
+//go:build linux
+
+package tap
+
+// subscribe will start a netlink subscription (such as netlink.LinkSubscribe
+// or netlink.NeighSubscribe), and return the channel of updates, along with
+// a function to stop the subscription. stop must be called exactly once,
+// when the caller is done reading updates.
+func subscribe[T any](sub func(chan<- T, <-chan struct{}) error) (<-chan T, func(), error) {
+	var (
+		done    = make(chan struct{})
+		updates = make(chan T)
+	)
+	if err := sub(updates, done); err != nil {
+		return nil, nil, err
+	}
+	stop := func() {
+		close(done)
+		// The netlink goroutine may be blocked on sending us an update;
+		// so we need to drain until it notices the socket is closed.
+		for range updates {
+		}
+	}
+	return updates, stop, nil
+}
+
+// vim: foldmethod=marker